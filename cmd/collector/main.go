@@ -2,16 +2,26 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
 	"fmt"
+	"io/ioutil"
+	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/kelseyhightower/envconfig"
 	"github.com/sters/spanner-query-stats-collector/stats"
-	"go.opentelemetry.io/contrib/exporters/metric/dogstatsd"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlphttp"
 	"go.opentelemetry.io/otel/exporters/stdout"
 	"go.opentelemetry.io/otel/metric/global"
 	controller "go.opentelemetry.io/otel/sdk/metric/controller/basic"
@@ -20,6 +30,8 @@ import (
 	"go.opentelemetry.io/otel/sdk/resource"
 	"go.uber.org/zap"
 	"golang.org/x/sync/errgroup"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/credentials"
 )
 
 type config struct {
@@ -29,16 +41,134 @@ type config struct {
 	CredentialFile string `envconfig:"CREDENTIAL_FILE"`
 	Writer         struct {
 		Mode      string `envconfig:"MODE" default:"stdout"`
+		QueueSize int    `envconfig:"QUEUE_SIZE" default:"16"`
+		Spool     struct {
+			Dir      string        `envconfig:"DIR"`
+			MaxBytes int64         `envconfig:"MAX_BYTES" default:"1073741824"`
+			MaxAge   time.Duration `envconfig:"MAX_AGE" default:"24h"`
+		} `envconfig:"SPOOL"`
+		Redact struct {
+			Literals bool   `envconfig:"LITERALS"`
+			Mask     string `envconfig:"MASK"`
+		} `envconfig:"REDACT"`
+		Filter struct {
+			Types             []string           `envconfig:"TYPES"`
+			IncludeText       string             `envconfig:"INCLUDE_TEXT"`
+			ExcludeText       string             `envconfig:"EXCLUDE_TEXT"`
+			AllowFingerprints []int64            `envconfig:"ALLOW_FINGERPRINTS"`
+			DenyFingerprints  []int64            `envconfig:"DENY_FINGERPRINTS"`
+			Thresholds        map[string]float64 `envconfig:"THRESHOLDS"`
+			SampleRate        float64            `envconfig:"SAMPLE_RATE" default:"1"`
+		} `envconfig:"FILTER"`
+		Async struct {
+			Enabled       bool          `envconfig:"ENABLED"`
+			QueueSize     int           `envconfig:"QUEUE_SIZE" default:"10000"`
+			Block         bool          `envconfig:"BLOCK"`
+			BatchSize     int           `envconfig:"BATCH_SIZE" default:"1000"`
+			FlushInterval time.Duration `envconfig:"FLUSH_INTERVAL" default:"10s"`
+		} `envconfig:"ASYNC"`
 		DogStatsd struct {
-			URL string `envconfig:"URL"`
+			URL        string   `envconfig:"URL"`
+			Prefix     string   `envconfig:"PREFIX" default:"spanner.stats"`
+			TextLength int      `envconfig:"TEXT_LENGTH"`
+			Tags       []string `envconfig:"TAGS"`
 		} `envconfig:"DOGSTATSD"`
+		OTLP     otlpConfig `envconfig:"OTLP"`
+		InfluxDB struct {
+			URL       string            `envconfig:"URL"`
+			Prefix    string            `envconfig:"PREFIX" default:"spanner_"`
+			Headers   map[string]string `envconfig:"HEADERS"`
+			BatchSize int               `envconfig:"BATCH_SIZE" default:"5000"`
+		} `envconfig:"INFLUXDB"`
+		JSONL struct {
+			Dir      string        `envconfig:"DIR" default:"."`
+			Prefix   string        `envconfig:"PREFIX" default:"spanner-stats-"`
+			MaxBytes int64         `envconfig:"MAX_BYTES" default:"104857600"`
+			MaxAge   time.Duration `envconfig:"MAX_AGE" default:"24h"`
+			Gzip     bool          `envconfig:"GZIP"`
+		} `envconfig:"JSONL"`
+		CSV struct {
+			Dir    string `envconfig:"DIR" default:"."`
+			Prefix string `envconfig:"PREFIX" default:"spanner-stats-"`
+		} `envconfig:"CSV"`
+		Webhook struct {
+			URL           string            `envconfig:"URL"`
+			Headers       map[string]string `envconfig:"HEADERS"`
+			Secret        string            `envconfig:"SECRET"`
+			Gzip          bool              `envconfig:"GZIP"`
+			MaxBatchSize  int               `envconfig:"MAX_BATCH_SIZE" default:"1000"`
+			RetryAttempts int               `envconfig:"RETRY_ATTEMPTS" default:"3"`
+			RetryBackoff  time.Duration     `envconfig:"RETRY_BACKOFF" default:"1s"`
+		} `envconfig:"WEBHOOK"`
+		Spanner struct {
+			Database    string `envconfig:"DATABASE"`
+			TablePrefix string `envconfig:"TABLE_PREFIX"`
+		} `envconfig:"SPANNER"`
+		SQLite struct {
+			Path string `envconfig:"PATH" default:"spanner-stats.db"`
+		} `envconfig:"SQLITE"`
+		Parquet struct {
+			Dir     string        `envconfig:"DIR" default:"."`
+			MaxRows int64         `envconfig:"MAX_ROWS" default:"1000000"`
+			MaxAge  time.Duration `envconfig:"MAX_AGE" default:"1h"`
+		} `envconfig:"PARQUET"`
+		Prometheus struct {
+			Addr       string        `envconfig:"ADDR" default:":9090"`
+			TTL        time.Duration `envconfig:"TTL"`
+			TextLength int           `envconfig:"TEXT_LENGTH" default:"64"`
+		} `envconfig:"PROMETHEUS"`
 	} `envconfig:"WRITER"`
-	StatDuration string `envconfig:"STAT_DURATION" default:"1min"`
+	StatDuration   string        `envconfig:"STAT_DURATION" default:"1min"`
+	SnapshotPeriod time.Duration `envconfig:"SNAPSHOT_PERIOD" default:"1m"`
+	Retry          struct {
+		Attempts int           `envconfig:"ATTEMPTS" default:"3"`
+		Backoff  time.Duration `envconfig:"BACKOFF" default:"1s"`
+	} `envconfig:"RETRY"`
+	Checkpoint struct {
+		Mode  string `envconfig:"MODE"`
+		File  string `envconfig:"FILE" default:"checkpoint.json"`
+		Table string `envconfig:"TABLE" default:"StatsCollectorCheckpoints"`
+	} `envconfig:"CHECKPOINT"`
+}
+
+// redacted returns the copy of the config without the secrets, for printing
+func (c config) redacted() config {
+	c.Writer.Webhook.Secret = redactedString(c.Writer.Webhook.Secret)
+	c.Writer.Webhook.Headers = redactedHeaders(c.Writer.Webhook.Headers)
+	c.Writer.InfluxDB.Headers = redactedHeaders(c.Writer.InfluxDB.Headers)
+	c.Writer.OTLP.Headers = redactedHeaders(c.Writer.OTLP.Headers)
+
+	return c
+}
+
+func redactedString(s string) string {
+	if s == "" {
+		return ""
+	}
+
+	return "REDACTED"
+}
+
+// redactedHeaders keeps the header names, the values like tokens are redacted
+func redactedHeaders(headers map[string]string) map[string]string {
+	if headers == nil {
+		return nil
+	}
+
+	result := make(map[string]string, len(headers))
+	for k, v := range headers {
+		result[k] = redactedString(v)
+	}
+
+	return result
 }
 
 const (
 	collectPeriod = 10 * time.Second
 	serviceName   = "spanner-query-stats-collector"
+
+	// defaultSpoolDir is the spool directory when WRITER_MODE has several modes without WRITER_SPOOL_DIR
+	defaultSpoolDir = "spool"
 )
 
 func main() {
@@ -65,68 +195,136 @@ func realmain() error {
 		return err
 	}
 
-	fmt.Printf("%#q", cfg)
+	// stdout is for the stats of the stdout writer
+	fmt.Fprintf(os.Stderr, "%+v\n", cfg.redacted())
 
-	var writer stats.Writer
+	var statDuration stats.StatDuration
 
-	switch cfg.Writer.Mode {
-	case "stdout", "log", "zap":
-		zapConfig := zap.NewProductionConfig()
-		zapConfig.OutputPaths = []string{"stdout"}
-		zapConfig.ErrorOutputPaths = []string{"stderr"}
-		logger, _ := zapConfig.Build()
-		defer func() { _ = logger.Sync() }()
-		writer = stats.NewZapWriter(logger)
+	switch cfg.StatDuration {
+	case "1min":
+		statDuration = stats.StatDurationMin
+	case "10min":
+		statDuration = stats.StatDuration10Min
+	case "1hour":
+		statDuration = stats.StatDurationHour
+	default:
+		return fmt.Errorf("invalid duration variable %s. must set '1min' or '10min' or '1hour'", cfg.StatDuration)
+	}
 
-	case "metricstdout", "dogstatsd":
-		f := map[string](func() (*controller.Controller, error)){
-			"metricstdout": func() (*controller.Controller, error) {
-				return initMetricstdout(ctx)
-			},
-			"dogstatsd": func() (*controller.Controller, error) {
-				if cfg.Writer.DogStatsd.URL == "" {
-					return nil, fmt.Errorf("failed to initialize dogstatsd exporter: unexpected dogstatsd URL")
-				}
+	errorLogger, err := zap.NewProduction()
+	if err != nil {
+		return fmt.Errorf("failed to initialize logger: %s", err)
+	}
+	defer func() { _ = errorLogger.Sync() }()
 
-				return initDogstatsd(ctx, cfg.Writer.DogStatsd.URL)
-			},
-		}[cfg.Writer.Mode]
+	var closers []func()
+	defer func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}()
 
-		pusher, err := f()
+	// canceled on shutdown, so the writers give up waiting for the retries instead of blocking the workers
+	writeCtx, cancelWrites := context.WithCancel(context.Background())
+	defer cancelWrites()
+
+	writers, closeWriters, err := newWriters(ctx, writeCtx, cfg, statDuration, errorLogger)
+	if err != nil {
+		return err
+	}
+	closers = append(closers, closeWriters)
+
+	writer := writers[0]
+	if len(writers) > 1 {
+		// the queued stats are written before closing the writers
+		multiWriter := stats.NewMultiWriter(
+			writers,
+			stats.WithMultiQueueSize(cfg.Writer.QueueSize),
+			stats.WithMultiLogger(errorLogger),
+		)
+		closers = append(closers, multiWriter.Close)
+		writer = multiWriter
+	}
+
+	// redacted before all writers, the filter still sees the original text
+	redactOpts, err := redactOptions(cfg)
+	if err != nil {
+		return err
+	}
+	if len(redactOpts) > 0 {
+		writer = stats.NewRedactWriter(writer, redactOpts...)
+	}
+
+	filterOpts, err := filterOptions(cfg)
+	if err != nil {
+		return err
+	}
+	if len(filterOpts) > 0 {
+		writer, err = stats.NewFilterWriter(writer, filterOpts...)
 		if err != nil {
-			return fmt.Errorf("failed to initialize opentelemetry: %s", err)
+			return fmt.Errorf("failed to initialize filter: %s", err)
 		}
+	}
 
-		global.SetMeterProvider(pusher.MeterProvider())
+	if cfg.Writer.Async.Enabled {
+		asyncWriter := stats.NewAsyncWriter(
+			writer,
+			stats.WithAsyncQueueSize(cfg.Writer.Async.QueueSize),
+			stats.WithAsyncBlock(cfg.Writer.Async.Block),
+			stats.WithAsyncBatchSize(cfg.Writer.Async.BatchSize),
+			stats.WithAsyncFlushInterval(cfg.Writer.Async.FlushInterval),
+			stats.WithAsyncLogger(errorLogger),
+		)
+		closers = append(closers, func() {
+			asyncWriter.Close()
+			if n := asyncWriter.Dropped(); n > 0 {
+				errorLogger.Warn("stats were dropped by the full queue", zap.Int64("dropped", n))
+			}
+		})
+		writer = asyncWriter
+	}
 
-		defer func() { _ = pusher.Stop(ctx) }()
-		writer = stats.NewOpenTelemetryWriter()
+	var checkpointStore stats.CheckpointStore
 
+	switch cfg.Checkpoint.Mode {
+	case "":
+	case "file":
+		checkpointStore = stats.NewFileCheckpointStore(cfg.Checkpoint.File)
+	case "spanner":
+		checkpointStore = stats.NewSpannerCheckpointStore(client, cfg.Checkpoint.Table)
 	default:
-		return fmt.Errorf("unexpected writer mode: %s", cfg.Writer.Mode)
+		return fmt.Errorf("unexpected checkpoint mode: %s", cfg.Checkpoint.Mode)
 	}
 
-	var statDuration stats.StatDuration
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		return backfill(ctx, client, statDuration, writer, checkpointStore, os.Args[2:])
+	}
 
-	switch cfg.StatDuration {
-	case "1min":
-		statDuration = stats.StatDurationMin
-	case "10min":
-		statDuration = stats.StatDuration10Min
-	case "1hour":
-		statDuration = stats.StatDurationHour
-	default:
-		return fmt.Errorf("invalid duration variable %s. must set '1min' or '10min' or '1hour'", cfg.StatDuration)
+	workerOpts := []stats.WorkerOption{
+		stats.WithLogger(errorLogger),
+		stats.WithRetry(cfg.Retry.Attempts, cfg.Retry.Backoff),
+	}
+	if checkpointStore != nil {
+		workerOpts = append(workerOpts, stats.WithCheckpointStore(checkpointStore))
 	}
 
 	worker := stats.NewWorker(
 		client,
 		statDuration,
 		writer,
+		workerOpts...,
+	)
+
+	snapshotWorker := stats.NewSnapshotWorker(
+		client,
+		cfg.SnapshotPeriod,
+		writer,
+		stats.WithSnapshotLogger(errorLogger),
 	)
 
 	eg, ctx := errgroup.WithContext(ctx)
 	eg.Go(func() error { worker.Start(ctx); return nil })
+	eg.Go(func() error { snapshotWorker.Start(ctx); return nil })
 
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGTERM, os.Interrupt)
@@ -135,11 +333,388 @@ func realmain() error {
 	case <-ctx.Done():
 	}
 
+	cancelWrites()
 	worker.Stop()
+	snapshotWorker.Stop()
 	return eg.Wait()
 }
 
-func otelControllerOptions() []controller.Option {
+// openTelemetryModes are the modes which export metrics through the global meter provider
+var openTelemetryModes = map[string]bool{
+	"metricstdout": true,
+	"otlp":         true,
+	"otlpgrpc":     true,
+	"otlphttp":     true,
+}
+
+// newWriters returns the writer of each mode in WRITER_MODE and the function to close them.
+// ctx is for initializing the writers, and writeCtx is canceled to stop retrying the writes.
+func newWriters(
+	ctx context.Context,
+	writeCtx context.Context,
+	cfg config,
+	statDuration stats.StatDuration,
+	errorLogger *zap.Logger,
+) ([]stats.Writer, func(), error) {
+	var (
+		writers []stats.Writer
+		closers []func()
+	)
+	closeWriters := func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}
+
+	modes := strings.Split(cfg.Writer.Mode, ",")
+
+	// MultiWriter can't report the failures of the writers before the checkpoint is saved,
+	// so the failed stats must be spooled
+	spoolDir := cfg.Writer.Spool.Dir
+	if spoolDir == "" && len(modes) > 1 {
+		spoolDir = defaultSpoolDir
+	}
+
+	seen := map[string]bool{}
+	otelMode := ""
+	for _, mode := range modes {
+		mode = strings.TrimSpace(mode)
+		if seen[mode] {
+			closeWriters()
+			return nil, nil, fmt.Errorf("duplicate writer mode: %s", mode)
+		}
+		seen[mode] = true
+
+		// each of them sets the global meter provider, which also exports the counters of the collector
+		if openTelemetryModes[mode] {
+			if otelMode != "" {
+				closeWriters()
+				return nil, nil, fmt.Errorf("only one of opentelemetry modes can be used: %s, %s", otelMode, mode)
+			}
+			otelMode = mode
+		}
+
+		w, closeWriter, err := newWriter(ctx, writeCtx, cfg, mode, statDuration, errorLogger)
+		if err != nil {
+			closeWriters()
+			return nil, nil, err
+		}
+		closers = append(closers, closeWriter)
+
+		if spoolDir != "" {
+			w, err = stats.NewSpoolWriter(w, stats.SpoolWriterConfig{
+				Dir:      filepath.Join(spoolDir, mode),
+				MaxBytes: cfg.Writer.Spool.MaxBytes,
+				MaxAge:   cfg.Writer.Spool.MaxAge,
+				Logger:   errorLogger,
+			})
+			if err != nil {
+				closeWriters()
+				return nil, nil, fmt.Errorf("failed to initialize spool: %s", err)
+			}
+		}
+		writers = append(writers, w)
+	}
+
+	return writers, closeWriters, nil
+}
+
+// newWriter returns the writer of the mode and the function to close it
+func newWriter(
+	ctx context.Context,
+	writeCtx context.Context,
+	cfg config,
+	mode string,
+	statDuration stats.StatDuration,
+	errorLogger *zap.Logger,
+) (stats.Writer, func(), error) {
+	var (
+		writer  stats.Writer
+		closers []func()
+	)
+
+	switch mode {
+	case "stdout", "log", "zap":
+		zapConfig := zap.NewProductionConfig()
+		zapConfig.OutputPaths = []string{"stdout"}
+		zapConfig.ErrorOutputPaths = []string{"stderr"}
+		logger, _ := zapConfig.Build()
+		closers = append(closers, func() { _ = logger.Sync() })
+		writer = stats.NewZapWriter(logger)
+
+	case "metricstdout", "otlp", "otlpgrpc", "otlphttp":
+		f := map[string](func() (*controller.Controller, error)){
+			"metricstdout": func() (*controller.Controller, error) {
+				return initMetricstdout(ctx)
+			},
+			"otlp": func() (*controller.Controller, error) {
+				return initOtlp(ctx, "otlp", cfg.Writer.OTLP)
+			},
+			"otlpgrpc": func() (*controller.Controller, error) {
+				return initOtlp(ctx, "otlpgrpc", cfg.Writer.OTLP)
+			},
+			"otlphttp": func() (*controller.Controller, error) {
+				return initOtlp(ctx, "otlphttp", cfg.Writer.OTLP)
+			},
+		}[mode]
+
+		pusher, err := f()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to initialize opentelemetry: %s", err)
+		}
+
+		global.SetMeterProvider(pusher.MeterProvider())
+
+		closers = append(closers, func() { _ = pusher.Stop(ctx) })
+		writer = stats.NewOpenTelemetryWriter()
+
+	case "dogstatsd":
+		if cfg.Writer.DogStatsd.URL == "" {
+			return nil, nil, fmt.Errorf("failed to initialize dogstatsd writer: unexpected dogstatsd URL")
+		}
+
+		w, err := stats.NewDogStatsdWriter(
+			cfg.Writer.DogStatsd.URL,
+			cfg.Writer.DogStatsd.Prefix,
+			cfg.Writer.DogStatsd.TextLength,
+			cfg.Writer.DogStatsd.Tags,
+		)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to initialize dogstatsd writer: %s", err)
+		}
+		writer = w
+
+	case "influxdb":
+		if cfg.Writer.InfluxDB.URL == "" {
+			return nil, nil, fmt.Errorf("failed to initialize influxdb writer: unexpected influxdb URL")
+		}
+
+		w, err := stats.NewInfluxDBWriter(
+			cfg.Writer.InfluxDB.URL,
+			cfg.Writer.InfluxDB.Prefix,
+			cfg.Writer.InfluxDB.Headers,
+			cfg.Writer.InfluxDB.BatchSize,
+			&http.Client{Timeout: 30 * time.Second},
+		)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to initialize influxdb writer: %s", err)
+		}
+		writer = w
+
+	case "webhook":
+		w, err := stats.NewWebhookWriter(stats.WebhookWriterConfig{
+			URL:           cfg.Writer.Webhook.URL,
+			Headers:       cfg.Writer.Webhook.Headers,
+			Secret:        cfg.Writer.Webhook.Secret,
+			Gzip:          cfg.Writer.Webhook.Gzip,
+			MaxBatchSize:  cfg.Writer.Webhook.MaxBatchSize,
+			RetryAttempts: cfg.Writer.Webhook.RetryAttempts,
+			RetryBackoff:  cfg.Writer.Webhook.RetryBackoff,
+			Client:        &http.Client{Timeout: 30 * time.Second},
+			Context:       writeCtx,
+			Logger:        errorLogger,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to initialize webhook writer: %s", err)
+		}
+		writer = w
+
+	case "jsonl":
+		w, err := stats.NewJSONLWriter(stats.JSONLWriterConfig{
+			Dir:      cfg.Writer.JSONL.Dir,
+			Prefix:   cfg.Writer.JSONL.Prefix,
+			MaxBytes: cfg.Writer.JSONL.MaxBytes,
+			MaxAge:   cfg.Writer.JSONL.MaxAge,
+			Gzip:     cfg.Writer.JSONL.Gzip,
+			Logger:   errorLogger,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to initialize jsonl writer: %s", err)
+		}
+
+		closers = append(closers, func() { _ = w.Close() })
+		writer = w
+
+	case "csv":
+		w, err := stats.NewCSVWriter(cfg.Writer.CSV.Dir, cfg.Writer.CSV.Prefix, stats.WithCSVLogger(errorLogger))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to initialize csv writer: %s", err)
+		}
+
+		closers = append(closers, func() { _ = w.Close() })
+		writer = w
+
+	case "parquet":
+		w, err := stats.NewParquetWriter(stats.ParquetWriterConfig{
+			Dir:     cfg.Writer.Parquet.Dir,
+			MaxRows: cfg.Writer.Parquet.MaxRows,
+			MaxAge:  cfg.Writer.Parquet.MaxAge,
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to initialize parquet writer: %s", err)
+		}
+
+		closers = append(closers, func() { _ = w.Close() })
+		writer = w
+
+	case "spanner":
+		db := cfg.Writer.Spanner.Database
+		if db == "" {
+			db = fmt.Sprintf("projects/%s/instances/%s/databases/%s", cfg.ProjectID, cfg.InstanceID, cfg.DatabaseID)
+		}
+
+		w, err := stats.NewSpannerHistoryWriter(
+			ctx,
+			db,
+			cfg.Writer.Spanner.TablePrefix,
+			errorLogger,
+			option.WithCredentialsFile(cfg.CredentialFile),
+		)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to initialize spanner history writer: %s", err)
+		}
+
+		closers = append(closers, w.Close)
+		writer = w
+
+	case "sqlite":
+		w, err := stats.NewSQLiteWriter(cfg.Writer.SQLite.Path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to initialize sqlite writer: %s", err)
+		}
+
+		closers = append(closers, func() { _ = w.Close() })
+		writer = w
+
+	case "otlplogs":
+		w, err := newOTLPLogWriter(cfg.Writer.OTLP)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to initialize otlp log writer: %s", err)
+		}
+		writer = w
+
+	case "prometheus":
+		ttl := cfg.Writer.Prometheus.TTL
+		if ttl == 0 {
+			// keep the series until a few intervals are missed.
+			ttl = 3 * statDuration.Duration()
+		}
+		promWriter := stats.NewPrometheusWriter(ttl, cfg.Writer.Prometheus.TextLength)
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promWriter)
+		server := &http.Server{Addr: cfg.Writer.Prometheus.Addr, Handler: mux}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				errorLogger.Error("failed to serve prometheus metrics", zap.Error(err))
+			}
+		}()
+
+		closers = append(closers, func() { _ = server.Shutdown(context.Background()) })
+		writer = promWriter
+
+	default:
+		return nil, nil, fmt.Errorf("unexpected writer mode: %s", mode)
+	}
+
+	return writer, func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}, nil
+}
+
+// redactOptions returns the redaction of WRITER_REDACT_*
+func redactOptions(cfg config) ([]stats.RedactOption, error) {
+	var opts []stats.RedactOption
+
+	if cfg.Writer.Redact.Literals {
+		opts = append(opts, stats.WithRedactLiterals())
+	}
+	if cfg.Writer.Redact.Mask != "" {
+		re, err := regexp.Compile(cfg.Writer.Redact.Mask)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse WRITER_REDACT_MASK: %s", err)
+		}
+		opts = append(opts, stats.WithRedactMasks(re))
+	}
+
+	return opts, nil
+}
+
+// filterOptions returns the rules of WRITER_FILTER_*
+func filterOptions(cfg config) ([]stats.FilterOption, error) {
+	var opts []stats.FilterOption
+
+	if len(cfg.Writer.Filter.Types) > 0 {
+		opts = append(opts, stats.WithFilterTypes(cfg.Writer.Filter.Types...))
+	}
+	if cfg.Writer.Filter.IncludeText != "" {
+		re, err := regexp.Compile(cfg.Writer.Filter.IncludeText)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse WRITER_FILTER_INCLUDE_TEXT: %s", err)
+		}
+		opts = append(opts, stats.WithFilterIncludeText(re))
+	}
+	if cfg.Writer.Filter.ExcludeText != "" {
+		re, err := regexp.Compile(cfg.Writer.Filter.ExcludeText)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse WRITER_FILTER_EXCLUDE_TEXT: %s", err)
+		}
+		opts = append(opts, stats.WithFilterExcludeText(re))
+	}
+	if len(cfg.Writer.Filter.AllowFingerprints) > 0 {
+		opts = append(opts, stats.WithFilterAllowFingerprints(cfg.Writer.Filter.AllowFingerprints...))
+	}
+	if len(cfg.Writer.Filter.DenyFingerprints) > 0 {
+		opts = append(opts, stats.WithFilterDenyFingerprints(cfg.Writer.Filter.DenyFingerprints...))
+	}
+	for field, min := range cfg.Writer.Filter.Thresholds {
+		opts = append(opts, stats.WithFilterThreshold(field, min))
+	}
+	if cfg.Writer.Filter.SampleRate < 1 {
+		opts = append(opts, stats.WithFilterSampleRate(cfg.Writer.Filter.SampleRate))
+	}
+
+	return opts, nil
+}
+
+func backfill(
+	ctx context.Context,
+	client *stats.Client,
+	statDuration stats.StatDuration,
+	writer stats.Writer,
+	checkpointStore stats.CheckpointStore,
+	args []string,
+) error {
+	fs := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	family := fs.String("family", "query", "stat family to backfill: query, transaction, lock, read, query_total, transaction_total, lock_total, table_size")
+	pageIntervals := fs.Int("page", 12, "number of intervals read at once")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	opts := []stats.BackfillOption{
+		stats.WithBackfillPageIntervals(*pageIntervals),
+	}
+	if checkpointStore != nil {
+		opts = append(opts, stats.WithBackfillCheckpointStore(checkpointStore))
+	} else {
+		fmt.Fprintln(os.Stderr, "*WARNING* Backfill can't resume without CHECKPOINT_MODE")
+	}
+
+	b, err := stats.NewBackfill(client, statDuration, *family, writer, opts...)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	return b.Run(ctx)
+}
+
+func otelControllerOptions(attrs ...attribute.KeyValue) []controller.Option {
 	host, err := os.Hostname()
 	if err != nil {
 		host = ""
@@ -148,10 +723,13 @@ func otelControllerOptions() []controller.Option {
 	return []controller.Option{
 		controller.WithCollectPeriod(collectPeriod),
 		controller.WithResource(
-			resource.NewWithAttributes(attribute.String("host", host)),
-		),
-		controller.WithResource(
-			resource.NewWithAttributes(attribute.String("service.name", serviceName)),
+			resource.NewWithAttributes(append(
+				[]attribute.KeyValue{
+					attribute.String("host", host),
+					attribute.String("service.name", serviceName),
+				},
+				attrs...,
+			)...),
 		),
 	}
 }
@@ -183,24 +761,139 @@ func initMetricstdout(ctx context.Context) (*controller.Controller, error) {
 	return pusher, nil
 }
 
-func initDogstatsd(ctx context.Context, url string) (*controller.Controller, error) {
-	// See: https://docs.datadoghq.com/ja/tagging/
-	fmt.Fprintln(os.Stderr, "*WARNING* Currently not fully supported dogstatsd export because SQL can't escaped for dd tags")
+type otlpConfig struct {
+	Endpoint           string            `envconfig:"ENDPOINT"`
+	LogsEndpoint       string            `envconfig:"LOGS_ENDPOINT"`
+	Headers            map[string]string `envconfig:"HEADERS"`
+	Insecure           bool              `envconfig:"INSECURE"`
+	CAFile             string            `envconfig:"CA_FILE"`
+	ResourceAttributes map[string]string `envconfig:"RESOURCE_ATTRIBUTES"`
+}
 
-	pusher, err := dogstatsd.NewExportPipeline(
-		dogstatsd.Config{
-			// The Writer field provides test support.
-			Writer: os.Stdout,
+func (c otlpConfig) tlsConfig() (*tls.Config, error) {
+	if c.CAFile == "" {
+		return &tls.Config{MinVersion: tls.VersionTLS12}, nil
+	}
 
-			// URL: fmt.Sprint("unix://", path),
-		},
-		otelControllerOptions()...,
-	)
+	pem, err := ioutil.ReadFile(c.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %s", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("failed to parse CA file: %s", c.CAFile)
+	}
+
+	return &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}, nil
+}
+
+func (c otlpConfig) resourceAttributes() []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(c.ResourceAttributes))
+	for k, v := range c.ResourceAttributes {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+
+	return attrs
+}
+
+func initOtlp(ctx context.Context, mode string, cfg otlpConfig) (*controller.Controller, error) {
+	tlsConfig, err := cfg.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var driver otlp.ProtocolDriver
+
+	switch mode {
+	case "otlp", "otlpgrpc":
+		opts := []otlpgrpc.Option{
+			otlpgrpc.WithHeaders(cfg.Headers),
+		}
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlpgrpc.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlpgrpc.WithInsecure())
+		} else {
+			opts = append(opts, otlpgrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig)))
+		}
+		driver = otlpgrpc.NewDriver(opts...)
+
+	case "otlphttp":
+		opts := []otlphttp.Option{
+			otlphttp.WithHeaders(cfg.Headers),
+		}
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlphttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlphttp.WithInsecure())
+		} else {
+			opts = append(opts, otlphttp.WithTLSClientConfig(tlsConfig))
+		}
+		driver = otlphttp.NewDriver(opts...)
+	}
+
+	exporter, err := otlp.NewExporter(ctx, driver)
 	if err != nil {
-		return nil, fmt.Errorf("could not initialize dogstatsd exporter: %s", err)
+		return nil, fmt.Errorf("failed to initialize otlp exporter: %s", err)
 	}
 
-	// note: pusher is already started inside dogstatsd package.
+	pusher := controller.New(
+		processor.New(
+			simple.NewWithExactDistribution(),
+			exporter,
+		),
+		append(
+			otelControllerOptions(cfg.resourceAttributes()...),
+			controller.WithExporter(exporter),
+		)...,
+	)
+
+	err = pusher.Start(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start pusher: %s", err)
+	}
 
 	return pusher, nil
 }
+
+func newOTLPLogWriter(cfg otlpConfig) (stats.Writer, error) {
+	// not Endpoint, which is the port of gRPC with otlpgrpc
+	endpoint := cfg.LogsEndpoint
+	if endpoint == "" {
+		endpoint = "localhost:4318"
+	}
+
+	scheme := "https"
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.Insecure {
+		scheme = "http"
+	} else {
+		tlsConfig, err := cfg.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		host = ""
+	}
+	resourceAttributes := map[string]string{
+		"host":         host,
+		"service.name": serviceName,
+	}
+	for k, v := range cfg.ResourceAttributes {
+		resourceAttributes[k] = v
+	}
+
+	return stats.NewOTLPLogWriter(
+		fmt.Sprintf("%s://%s/v1/logs", scheme, endpoint),
+		cfg.Headers,
+		&http.Client{Transport: transport, Timeout: 30 * time.Second},
+		resourceAttributes,
+	), nil
+}
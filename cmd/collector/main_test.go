@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sters/spanner-query-stats-collector/stats"
+	"go.opentelemetry.io/otel/metric"
+	collectormetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestConfigRedacted(t *testing.T) {
+	cfg := config{}
+	cfg.Writer.Webhook.Secret = "webhook-secret"
+	cfg.Writer.Webhook.Headers = map[string]string{"Authorization": "Bearer webhook-token"}
+	cfg.Writer.InfluxDB.Headers = map[string]string{"Authorization": "Token influxdb-token"}
+	cfg.Writer.OTLP.Headers = map[string]string{"X-Api-Key": "otlp-token"}
+
+	out := fmt.Sprintf("%+v", cfg.redacted())
+	for _, secret := range []string{"webhook-secret", "webhook-token", "influxdb-token", "otlp-token"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("%s is printed: %s", secret, out)
+		}
+	}
+	if !strings.Contains(out, "X-Api-Key") {
+		t.Errorf("header names are not printed: %s", out)
+	}
+
+	// the original config is used to write
+	if cfg.Writer.Webhook.Secret != "webhook-secret" || cfg.Writer.OTLP.Headers["X-Api-Key"] != "otlp-token" {
+		t.Errorf("the original config is modified: %+v", cfg)
+	}
+}
+
+// otlpRequest is what the stand-in collector received
+type otlpRequest struct {
+	header  string
+	request *collectormetricpb.ExportMetricsServiceRequest
+}
+
+type otlpGRPCCollector struct {
+	collectormetricpb.UnimplementedMetricsServiceServer
+
+	mu       sync.Mutex
+	requests []otlpRequest
+}
+
+func (c *otlpGRPCCollector) Export(ctx context.Context, req *collectormetricpb.ExportMetricsServiceRequest) (*collectormetricpb.ExportMetricsServiceResponse, error) {
+	var header string
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get("x-test-token")) > 0 {
+		header = md.Get("x-test-token")[0]
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, otlpRequest{header: header, request: req})
+
+	return &collectormetricpb.ExportMetricsServiceResponse{}, nil
+}
+
+func TestInitOtlp(t *testing.T) {
+	for _, mode := range []string{"otlp", "otlpgrpc", "otlphttp"} {
+		mode := mode
+		t.Run(mode, func(t *testing.T) {
+			var (
+				endpoint string
+				received func() []otlpRequest
+			)
+
+			if mode == "otlphttp" {
+				var (
+					mu       sync.Mutex
+					requests []otlpRequest
+				)
+				server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+					if r.URL.Path != "/v1/metrics" {
+						rw.WriteHeader(http.StatusNotFound)
+						return
+					}
+
+					body, err := ioutil.ReadAll(r.Body)
+					if err != nil {
+						rw.WriteHeader(http.StatusBadRequest)
+						return
+					}
+					req := &collectormetricpb.ExportMetricsServiceRequest{}
+					if err := proto.Unmarshal(body, req); err != nil {
+						rw.WriteHeader(http.StatusBadRequest)
+						return
+					}
+
+					mu.Lock()
+					defer mu.Unlock()
+					requests = append(requests, otlpRequest{header: r.Header.Get("X-Test-Token"), request: req})
+				}))
+				defer server.Close()
+
+				endpoint = strings.TrimPrefix(server.URL, "http://")
+				received = func() []otlpRequest {
+					mu.Lock()
+					defer mu.Unlock()
+					return append([]otlpRequest{}, requests...)
+				}
+			} else {
+				lis, err := net.Listen("tcp", "127.0.0.1:0")
+				if err != nil {
+					t.Fatal(err)
+				}
+				collector := &otlpGRPCCollector{}
+				server := grpc.NewServer()
+				collectormetricpb.RegisterMetricsServiceServer(server, collector)
+				go func() { _ = server.Serve(lis) }()
+				defer server.Stop()
+
+				endpoint = lis.Addr().String()
+				received = func() []otlpRequest {
+					collector.mu.Lock()
+					defer collector.mu.Unlock()
+					return append([]otlpRequest{}, collector.requests...)
+				}
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			pusher, err := initOtlp(ctx, mode, otlpConfig{
+				Endpoint:           endpoint,
+				Headers:            map[string]string{"X-Test-Token": "secret"},
+				Insecure:           true,
+				ResourceAttributes: map[string]string{"deployment.environment": "test"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			counter := metric.Must(pusher.MeterProvider().Meter("test")).NewInt64Counter("test.counter")
+			counter.Add(ctx, 1)
+
+			// stopping exports the metrics one last time
+			if err := pusher.Stop(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			requests := received()
+			if len(requests) == 0 {
+				t.Fatal("no metrics are exported")
+			}
+
+			if requests[0].header != "secret" {
+				t.Errorf("unexpected header: %q", requests[0].header)
+			}
+
+			attrs := map[string]string{}
+			for _, rm := range requests[0].request.ResourceMetrics {
+				for _, kv := range rm.Resource.Attributes {
+					attrs[kv.Key] = kv.Value.GetStringValue()
+				}
+			}
+			if attrs["deployment.environment"] != "test" || attrs["service.name"] != serviceName {
+				t.Errorf("unexpected resource attributes: %v", attrs)
+			}
+		})
+	}
+}
+
+func TestNewWriters(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	dir := t.TempDir()
+	cfg := config{}
+	cfg.Writer.Mode = "stdout, jsonl,csv"
+	cfg.Writer.JSONL.Dir = dir
+	cfg.Writer.CSV.Dir = dir
+	cfg.Writer.Spool.Dir = filepath.Join(dir, "spool")
+
+	writers, closeWriters, err := newWriters(ctx, ctx, cfg, 0, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeWriters()
+
+	// each mode gets its own sink with its own spool
+	modes := []string{"stdout", "jsonl", "csv"}
+	if len(writers) != len(modes) {
+		t.Fatalf("unexpected writers: %d", len(writers))
+	}
+	for i, mode := range modes {
+		if _, ok := writers[i].(*stats.SpoolWriter); !ok {
+			t.Errorf("the writer of %s is not spooled: %T", mode, writers[i])
+		}
+		if _, err := os.Stat(filepath.Join(cfg.Writer.Spool.Dir, mode)); err != nil {
+			t.Errorf("no spool of %s: %s", mode, err)
+		}
+	}
+}
+
+func TestNewWritersOfSingleMode(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cfg := config{}
+	cfg.Writer.Mode = "stdout"
+
+	writers, closeWriters, err := newWriters(ctx, ctx, cfg, 0, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeWriters()
+
+	if len(writers) != 1 {
+		t.Fatalf("unexpected writers: %d", len(writers))
+	}
+	if _, ok := writers[0].(*stats.SpoolWriter); ok {
+		t.Error("the single writer is spooled without WRITER_SPOOL_DIR")
+	}
+}
+
+func TestNewWritersOfInvalidModes(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, mode := range []string{
+		"stdout,stdout",
+		"stdout, stdout",
+		"stdout,unknown",
+		"stdout,",
+		"",
+		// they set the same global meter provider
+		"metricstdout,otlphttp",
+		"otlp,otlpgrpc",
+	} {
+		t.Run(mode, func(t *testing.T) {
+			cfg := config{}
+			cfg.Writer.Mode = mode
+			cfg.Writer.Spool.Dir = t.TempDir()
+
+			if _, _, err := newWriters(ctx, ctx, cfg, 0, zap.NewNop()); err == nil {
+				t.Errorf("no error for %q", mode)
+			}
+		})
+	}
+}
+
+func TestNewOTLPLogWriter(t *testing.T) {
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+	}))
+	defer server.Close()
+
+	// the endpoint of otlpgrpc is not used for the logs
+	w, err := newOTLPLogWriter(otlpConfig{
+		Endpoint:     "127.0.0.1:1",
+		LogsEndpoint: strings.TrimPrefix(server.URL, "http://"),
+		Insecure:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(nil); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/v1/logs" {
+		t.Errorf("unexpected path: %q", path)
+	}
+}
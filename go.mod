@@ -8,14 +8,21 @@ require (
 	github.com/golangci/golangci-lint v1.42.1
 	github.com/kelseyhightower/envconfig v1.4.0
 	github.com/quasilyte/go-consistent v0.0.0-20200404105227-766526bf1e96
-	go.opentelemetry.io/contrib/exporters/metric/dogstatsd v0.20.0
+	github.com/xitongsys/parquet-go v1.6.2
+	github.com/xitongsys/parquet-go-source v0.0.0-20200817004010-026bad9b25d0
 	go.opentelemetry.io/otel v0.20.0
+	go.opentelemetry.io/otel/exporters/otlp v0.20.0
 	go.opentelemetry.io/otel/exporters/stdout v0.20.0
 	go.opentelemetry.io/otel/metric v0.20.0
 	go.opentelemetry.io/otel/sdk v0.20.0
 	go.opentelemetry.io/otel/sdk/metric v0.20.0
+	go.opentelemetry.io/proto/otlp v0.7.0
 	go.uber.org/zap v1.19.1
 	golang.org/x/net v0.0.0-20210510120150-4163338589ed // indirect
 	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
 	google.golang.org/api v0.60.0
+	google.golang.org/genproto v0.0.0-20211021150943-2b146023228c
+	google.golang.org/grpc v1.40.0
+	google.golang.org/protobuf v1.27.1
+	modernc.org/sqlite v1.14.1
 )
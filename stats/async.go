@@ -0,0 +1,240 @@
+package stats
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultAsyncQueueSize     = 10000
+	defaultAsyncBatchSize     = 1000
+	defaultAsyncFlushInterval = 10 * time.Second
+)
+
+// Flusher is implemented by the Writers which buffer stats. Worker.Stop flushes the Writer if it implements Flusher.
+type Flusher interface {
+	// Flush writes the buffered stats and waits until written
+	Flush()
+}
+
+// AsyncWriter buffers stats and writes them to the underlying Writer in the background,
+// so a slow Writer doesn't delay the next collection.
+type AsyncWriter struct {
+	writer        Writer
+	queue         chan stat
+	block         bool
+	batchSize     int
+	flushInterval time.Duration
+	logger        *zap.Logger
+
+	// mu is held by Write while queueing, so Close can wait for them before the last drain
+	mu      sync.RWMutex
+	flushCh chan chan struct{}
+	closeCh chan struct{}
+	stopCh  chan struct{}
+	done    chan struct{}
+	once    sync.Once
+
+	dropped        int64
+	droppedCounter metric.Int64Counter
+}
+
+// AsyncWriterOption configures the AsyncWriter
+type AsyncWriterOption func(*AsyncWriter)
+
+// WithAsyncQueueSize sets the max stats buffered
+func WithAsyncQueueSize(size int) AsyncWriterOption {
+	return func(w *AsyncWriter) {
+		if size > 0 {
+			w.queue = make(chan stat, size)
+		}
+	}
+}
+
+// WithAsyncBlock makes Write wait for the space of the queue instead of dropping stats
+func WithAsyncBlock(block bool) AsyncWriterOption {
+	return func(w *AsyncWriter) {
+		w.block = block
+	}
+}
+
+// WithAsyncBatchSize sets the max stats written at once
+func WithAsyncBatchSize(size int) AsyncWriterOption {
+	return func(w *AsyncWriter) {
+		if size > 0 {
+			w.batchSize = size
+		}
+	}
+}
+
+// WithAsyncFlushInterval sets the interval to write the buffered stats even if less than the batch size
+func WithAsyncFlushInterval(interval time.Duration) AsyncWriterOption {
+	return func(w *AsyncWriter) {
+		if interval > 0 {
+			w.flushInterval = interval
+		}
+	}
+}
+
+// WithAsyncLogger sets the logger for the errors of the underlying Writer
+func WithAsyncLogger(logger *zap.Logger) AsyncWriterOption {
+	return func(w *AsyncWriter) {
+		w.logger = logger
+	}
+}
+
+// NewAsyncWriter return new AsyncWriter of the writer.
+// By default, stats are dropped when the queue is full, and the dropped stats are counted as
+// the OpenTelemetry counter "spanner.stats.collector.Dropped". Close it to write the rest.
+func NewAsyncWriter(writer Writer, opts ...AsyncWriterOption) *AsyncWriter {
+	w := &AsyncWriter{
+		writer:        writer,
+		queue:         make(chan stat, defaultAsyncQueueSize),
+		batchSize:     defaultAsyncBatchSize,
+		flushInterval: defaultAsyncFlushInterval,
+		logger:        newDefaultLogger(),
+		flushCh:       make(chan chan struct{}),
+		closeCh:       make(chan struct{}),
+		stopCh:        make(chan struct{}),
+		done:          make(chan struct{}),
+		droppedCounter: metric.Must(global.Meter(otelMeterNameCollector)).
+			NewInt64Counter(otelMeterNameCollector + ".Dropped"),
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	go w.run()
+
+	return w
+}
+
+// Write queues stats collection. The stats dropped by the full queue or after Close are counted and logged,
+// but not returned, because the caller would write the queued ones again.
+// The errors of the underlying Writer are logged.
+func (w *AsyncWriter) Write(stats []stat) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	var dropped int64
+	for _, s := range stats {
+		select {
+		case <-w.closeCh:
+			dropped++
+			continue
+		default:
+		}
+
+		if w.block {
+			select {
+			case w.queue <- s:
+			case <-w.closeCh:
+				dropped++
+			}
+			continue
+		}
+
+		select {
+		case w.queue <- s:
+		default:
+			dropped++
+		}
+	}
+
+	if dropped > 0 {
+		n := atomic.AddInt64(&w.dropped, dropped)
+		w.droppedCounter.Add(context.Background(), dropped)
+		w.logger.Warn("dropped stats by the full queue", zap.Int64("dropped", dropped), zap.Int64("total_dropped", n))
+	}
+
+	return nil
+}
+
+// Flush writes the queued stats and waits until written
+func (w *AsyncWriter) Flush() {
+	ch := make(chan struct{})
+	select {
+	case w.flushCh <- ch:
+		<-ch
+	case <-w.done:
+	}
+}
+
+// Close writes the queued stats and stops the background goroutine. Stats written after Close are dropped.
+func (w *AsyncWriter) Close() {
+	w.once.Do(func() {
+		// the blocked Writes give up, and the running ones finish queueing before the last drain
+		close(w.closeCh)
+		w.mu.Lock()
+		close(w.stopCh)
+		w.mu.Unlock()
+	})
+	<-w.done
+}
+
+// Dropped returns the number of stats dropped because the queue was full or after Close
+func (w *AsyncWriter) Dropped() int64 {
+	return atomic.LoadInt64(&w.dropped)
+}
+
+func (w *AsyncWriter) run() {
+	defer close(w.done)
+
+	t := time.NewTicker(w.flushInterval)
+	defer t.Stop()
+
+	batch := make([]stat, 0, w.batchSize)
+	write := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := w.writer.Write(batch); err != nil {
+			w.logger.Error("failed to write buffered stats", zap.Error(err))
+		}
+		batch = make([]stat, 0, w.batchSize)
+	}
+	// drain takes the stats queued until now
+	drain := func() {
+		for {
+			select {
+			case s := <-w.queue:
+				batch = append(batch, s)
+				if len(batch) >= w.batchSize {
+					write()
+				}
+			default:
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case s := <-w.queue:
+			batch = append(batch, s)
+			if len(batch) >= w.batchSize {
+				write()
+			}
+
+		case <-t.C:
+			write()
+
+		case ch := <-w.flushCh:
+			drain()
+			write()
+			close(ch)
+
+		case <-w.stopCh:
+			drain()
+			write()
+			return
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package stats
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// gatedWriter notifies each Write and waits for release, like a slow sink
+type gatedWriter struct {
+	entered chan struct{}
+	release chan struct{}
+
+	mu      sync.Mutex
+	written []stat
+}
+
+func newGatedWriter() *gatedWriter {
+	return &gatedWriter{
+		entered: make(chan struct{}, 100),
+		release: make(chan struct{}),
+	}
+}
+
+func (w *gatedWriter) Write(stats []stat) error {
+	w.entered <- struct{}{}
+	<-w.release
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.written = append(w.written, stats...)
+
+	return nil
+}
+
+func (w *gatedWriter) count() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return len(w.written)
+}
+
+func newAsyncTestStats(n int) []stat {
+	stats := make([]stat, 0, n)
+	for i := 0; i < n; i++ {
+		stats = append(stats, &QueryStat{Text: "SELECT 1", TextFingerprint: int64(i)})
+	}
+
+	return stats
+}
+
+func TestAsyncWriterDrop(t *testing.T) {
+	out := newGatedWriter()
+	w := NewAsyncWriter(out, WithAsyncQueueSize(2), WithAsyncBatchSize(1), WithAsyncLogger(zap.NewNop()))
+
+	// the first one is taken by the background goroutine, which waits in the writer
+	if err := w.Write(newAsyncTestStats(1)); err != nil {
+		t.Fatal(err)
+	}
+	<-out.entered
+
+	// two of four are queued, the rest are dropped without error, so the queued ones are not written again
+	if err := w.Write(newAsyncTestStats(4)); err != nil {
+		t.Errorf("dropped stats are returned: %s", err)
+	}
+	if n := w.Dropped(); n != 2 {
+		t.Errorf("unexpected dropped: %d", n)
+	}
+
+	close(out.release)
+	w.Close()
+
+	if n := out.count(); n != 3 {
+		t.Errorf("unexpected written: %d", n)
+	}
+}
+
+func TestAsyncWriterBlock(t *testing.T) {
+	out := newGatedWriter()
+	w := NewAsyncWriter(
+		out,
+		WithAsyncQueueSize(2),
+		WithAsyncBatchSize(1),
+		WithAsyncBlock(true),
+		WithAsyncLogger(zap.NewNop()),
+	)
+
+	if err := w.Write(newAsyncTestStats(1)); err != nil {
+		t.Fatal(err)
+	}
+	<-out.entered
+
+	written := make(chan error)
+	go func() { written <- w.Write(newAsyncTestStats(4)) }()
+
+	select {
+	case <-written:
+		t.Fatal("Write doesn't wait for the space of the queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(out.release)
+	if err := <-written; err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	if n := out.count(); n != 5 {
+		t.Errorf("unexpected written: %d", n)
+	}
+	if n := w.Dropped(); n != 0 {
+		t.Errorf("unexpected dropped: %d", n)
+	}
+}
+
+func TestAsyncWriterCloseUnblocksWrite(t *testing.T) {
+	out := newGatedWriter()
+	w := NewAsyncWriter(
+		out,
+		WithAsyncQueueSize(2),
+		WithAsyncBatchSize(1),
+		WithAsyncBlock(true),
+		WithAsyncLogger(zap.NewNop()),
+	)
+
+	if err := w.Write(newAsyncTestStats(1)); err != nil {
+		t.Fatal(err)
+	}
+	<-out.entered
+
+	written := make(chan error)
+	go func() { written <- w.Write(newAsyncTestStats(4)) }()
+
+	// wait until Write blocks on the full queue
+	for len(w.queue) < cap(w.queue) {
+		time.Sleep(time.Millisecond)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		w.Close()
+		close(closed)
+	}()
+
+	// the blocked Write gives up the stats which don't fit
+	if err := <-written; err != nil {
+		t.Fatal(err)
+	}
+	if n := w.Dropped(); n != 2 {
+		t.Errorf("unexpected dropped: %d", n)
+	}
+
+	close(out.release)
+	<-closed
+
+	if n := out.count(); n != 3 {
+		t.Errorf("unexpected written: %d", n)
+	}
+}
+
+func TestAsyncWriterFlush(t *testing.T) {
+	out := &failingWriter{}
+	w := NewAsyncWriter(out, WithAsyncBatchSize(100), WithAsyncFlushInterval(time.Hour), WithAsyncLogger(zap.NewNop()))
+	defer w.Close()
+
+	if err := w.Write(newAsyncTestStats(3)); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+
+	if len(out.written) != 3 {
+		t.Errorf("unexpected written: %d", len(out.written))
+	}
+}
+
+func TestAsyncWriterClose(t *testing.T) {
+	out := &failingWriter{}
+	w := NewAsyncWriter(out, WithAsyncBatchSize(100), WithAsyncFlushInterval(time.Hour), WithAsyncLogger(zap.NewNop()))
+
+	if err := w.Write(newAsyncTestStats(3)); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	if len(out.written) != 3 {
+		t.Errorf("the queued stats are not written: %d", len(out.written))
+	}
+
+	// the stats after Close are counted as dropped, instead of lost in the queue
+	if err := w.Write(newAsyncTestStats(2)); err != nil {
+		t.Fatal(err)
+	}
+	if n := w.Dropped(); n != 2 {
+		t.Errorf("unexpected dropped: %d", n)
+	}
+
+	// Flush and Close after Close don't block
+	w.Flush()
+	w.Close()
+}
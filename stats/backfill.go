@@ -0,0 +1,116 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const defaultBackfillPageIntervals = 12
+
+// Backfill walks every available interval of a stat family within the retention window of Spanner.
+type Backfill struct {
+	family          *statFamily
+	writer          Writer
+	checkpointStore CheckpointStore
+	pageIntervals   int
+}
+
+// BackfillOption configures the Backfill
+type BackfillOption func(*Backfill)
+
+// WithBackfillCheckpointStore enables to resume the Backfill from the last completed page
+func WithBackfillCheckpointStore(store CheckpointStore) BackfillOption {
+	return func(b *Backfill) {
+		b.checkpointStore = store
+	}
+}
+
+// WithBackfillPageIntervals sets how many intervals are read at once
+func WithBackfillPageIntervals(n int) BackfillOption {
+	return func(b *Backfill) {
+		if n > 0 {
+			b.pageIntervals = n
+		}
+	}
+}
+
+// NewBackfill returns the new Backfill of the named stat family
+func NewBackfill(client *Client, statType StatDuration, familyName string, writer Writer, opts ...BackfillOption) (*Backfill, error) {
+	var family *statFamily
+	for _, f := range append(newStatFamilies(client, statType), newHourlyStatFamilies(client)...) {
+		if f.name == familyName {
+			family = f
+			break
+		}
+	}
+	if family == nil {
+		return nil, fmt.Errorf("unknown stat family: %s", familyName)
+	}
+
+	b := &Backfill{
+		family:        family,
+		writer:        writer,
+		pageIntervals: defaultBackfillPageIntervals,
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b, nil
+}
+
+// checkpointKey is separated from the Worker, for running both at the same time.
+func (b *Backfill) checkpointKey() string {
+	return "backfill/" + b.family.checkpointKey()
+}
+
+// Run the backfill until the current time
+func (b *Backfill) Run(ctx context.Context) error {
+	now := time.Now()
+	from := now.Add(-b.family.statType.Retention())
+
+	if b.checkpointStore != nil {
+		t, ok, err := b.checkpointStore.Load(ctx, b.checkpointKey())
+		if err != nil {
+			return fmt.Errorf("failed to load checkpoint of %s: %w", b.checkpointKey(), err)
+		}
+		if ok && t.After(from) {
+			from = t
+		}
+	}
+
+	page := time.Duration(b.pageIntervals) * b.family.statType.Duration()
+
+	for from.Before(now) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		to := from.Add(page)
+		if to.After(now) {
+			to = now
+		}
+
+		stats, err := b.family.getter(ctx, b.family.statType, from, to)
+		if err != nil {
+			return fmt.Errorf("failed to collect %s from %s to %s: %w", b.family.checkpointKey(), from, to, err)
+		}
+		if len(stats) > 0 {
+			if err := b.writer.Write(stats); err != nil {
+				return fmt.Errorf("failed to write %s from %s to %s: %w", b.family.checkpointKey(), from, to, err)
+			}
+		}
+
+		if b.checkpointStore != nil {
+			if err := b.checkpointStore.Save(ctx, b.checkpointKey(), to); err != nil {
+				return fmt.Errorf("failed to save checkpoint of %s: %w", b.checkpointKey(), err)
+			}
+		}
+
+		from = to
+	}
+
+	return nil
+}
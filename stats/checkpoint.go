@@ -0,0 +1,149 @@
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+)
+
+// CheckpointStore persists lastIntervalEnd of each stat family, so the Worker can resume after restarts.
+type CheckpointStore interface {
+	// Load returns the saved lastIntervalEnd. ok is false if there is no checkpoint yet.
+	Load(ctx context.Context, key string) (lastIntervalEnd time.Time, ok bool, err error)
+	// Save the lastIntervalEnd
+	Save(ctx context.Context, key string, lastIntervalEnd time.Time) error
+}
+
+type fileCheckpointStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileCheckpointStore return new CheckpointStore of local JSON file
+func NewFileCheckpointStore(path string) CheckpointStore {
+	return &fileCheckpointStore{
+		path: path,
+	}
+}
+
+func (s *fileCheckpointStore) Load(_ context.Context, key string) (time.Time, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checkpoints, err := s.read()
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	t, ok := checkpoints[key]
+	return t, ok, nil
+}
+
+func (s *fileCheckpointStore) Save(_ context.Context, key string, lastIntervalEnd time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checkpoints, err := s.read()
+	if err != nil {
+		return err
+	}
+	checkpoints[key] = lastIntervalEnd
+
+	b, err := json.MarshalIndent(checkpoints, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// write to temporary file and rename it, for not breaking the checkpoint on crash.
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func (s *fileCheckpointStore) read() (map[string]time.Time, error) {
+	checkpoints := map[string]time.Time{}
+
+	b, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return checkpoints, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, &checkpoints); err != nil {
+		return nil, err
+	}
+
+	return checkpoints, nil
+}
+
+// SpannerCheckpointTableDDL is the DDL of the table for NewSpannerCheckpointStore. Format it with the table name.
+const SpannerCheckpointTableDDL = `CREATE TABLE %s (
+	Name STRING(MAX) NOT NULL,
+	LastIntervalEnd TIMESTAMP NOT NULL,
+	UpdatedAt TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp=true),
+) PRIMARY KEY (Name)`
+
+type spannerCheckpointStore struct {
+	client *Client
+	table  string
+}
+
+// NewSpannerCheckpointStore return new CheckpointStore of the table in collecting database.
+// The table must be created before using, see SpannerCheckpointTableDDL.
+func NewSpannerCheckpointStore(client *Client, table string) CheckpointStore {
+	return &spannerCheckpointStore{
+		client: client,
+		table:  table,
+	}
+}
+
+func (s *spannerCheckpointStore) Load(ctx context.Context, key string) (time.Time, bool, error) {
+	row, err := s.client.spannerClient.Single().ReadRow(ctx, s.table, spanner.Key{key}, []string{"LastIntervalEnd"})
+	if err != nil {
+		if spanner.ErrCode(err) == codes.NotFound {
+			return time.Time{}, false, nil
+		}
+		return time.Time{}, false, err
+	}
+
+	var t time.Time
+	if err := row.Column(0, &t); err != nil {
+		return time.Time{}, false, err
+	}
+
+	return t, true, nil
+}
+
+func (s *spannerCheckpointStore) Save(ctx context.Context, key string, lastIntervalEnd time.Time) error {
+	_, err := s.client.spannerClient.Apply(ctx, []*spanner.Mutation{
+		spanner.InsertOrUpdate(
+			s.table,
+			[]string{"Name", "LastIntervalEnd", "UpdatedAt"},
+			[]interface{}{key, lastIntervalEnd, spanner.CommitTimestamp},
+		),
+	})
+
+	return err
+}
@@ -0,0 +1,223 @@
+package stats
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// CSVWriter writes stats into CSV files, one file per stat family and day.
+// Files are named like "<prefix>query-2006-01-02.csv" by IntervalEnd in UTC, and the header row is the
+// snake_case fields of the stat struct. Times are RFC3339, durations are nanoseconds,
+// and array fields like ReadColumns and SampleLockRequests are JSON encoded.
+type CSVWriter struct {
+	dir    string
+	prefix string
+	logger *zap.Logger
+
+	mu    sync.Mutex
+	files map[string]*csvFile
+}
+
+type csvFile struct {
+	date   string
+	file   *os.File
+	writer *csv.Writer
+}
+
+// CSVWriterOption configures the CSVWriter
+type CSVWriterOption func(*CSVWriter)
+
+// WithCSVLogger sets the logger for the skipped stats
+func WithCSVLogger(logger *zap.Logger) CSVWriterOption {
+	return func(w *CSVWriter) {
+		w.logger = logger
+	}
+}
+
+// NewCSVWriter return new CSVWriter. Close it to flush the files.
+func NewCSVWriter(dir string, prefix string, opts ...CSVWriterOption) (*CSVWriter, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	w := &CSVWriter{
+		dir:    dir,
+		prefix: prefix,
+		logger: newDefaultLogger(),
+		files:  map[string]*csvFile{},
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w, nil
+}
+
+// Write stats collection as CSV rows
+func (w *CSVWriter) Write(stats []stat) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, s := range stats {
+		row, err := csvRow(s)
+		if err != nil {
+			// writing again doesn't help, so it is skipped
+			w.logger.Error("failed to encode stat", zap.String("type", statTypeName(s)), zap.Error(err))
+			continue
+		}
+
+		f, err := w.file(s)
+		if err != nil {
+			return fmt.Errorf("failed to open csv file: %w", err)
+		}
+
+		if err := f.writer.Write(row); err != nil {
+			return fmt.Errorf("failed to write csv file: %w", err)
+		}
+	}
+
+	for _, f := range w.files {
+		f.writer.Flush()
+		if err := f.writer.Error(); err != nil {
+			return fmt.Errorf("failed to write csv file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Close the files
+func (w *CSVWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var err error
+	for family, f := range w.files {
+		if closeErr := f.close(); err == nil {
+			err = closeErr
+		}
+		delete(w.files, family)
+	}
+
+	return err
+}
+
+// file returns the file of the family and the date of the stat, rolling over the file of the other date
+func (w *CSVWriter) file(s stat) (*csvFile, error) {
+	family := statFamilyName(s)
+	date := s.getIntervalEnd().UTC().Format("2006-01-02")
+
+	if f, ok := w.files[family]; ok {
+		if f.date == date {
+			return f, nil
+		}
+
+		delete(w.files, family)
+		if err := f.close(); err != nil {
+			return nil, err
+		}
+	}
+
+	path := filepath.Join(w.dir, fmt.Sprintf("%s%s-%s.csv", w.prefix, family, date))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &csvFile{
+		date:   date,
+		file:   file,
+		writer: csv.NewWriter(file),
+	}
+
+	// the file may exist already after restarting
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.Size() == 0 {
+		if err := f.writer.Write(csvHeader(s)); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	w.files[family] = f
+
+	return f, nil
+}
+
+func (f *csvFile) close() error {
+	f.writer.Flush()
+	err := f.writer.Error()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+func csvHeader(s stat) []string {
+	fields := statFields(s)
+	header := make([]string, 0, len(fields))
+	for _, f := range fields {
+		header = append(header, toSnakeCase(f.name))
+	}
+
+	return header
+}
+
+func csvRow(s stat) ([]string, error) {
+	fields := statFields(s)
+	row := make([]string, 0, len(fields))
+	for _, f := range fields {
+		v, err := csvValue(f.value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", f.name, err)
+		}
+		row = append(row, v)
+	}
+
+	return row, nil
+}
+
+func csvValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano), nil
+	case time.Duration:
+		return strconv.FormatInt(int64(v), 10), nil
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+
+	// nil slices are written as an empty array rather than null
+	if string(b) == "null" {
+		return "[]", nil
+	}
+
+	return string(b), nil
+}
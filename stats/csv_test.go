@@ -0,0 +1,159 @@
+package stats
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func newCSVTestWriter(t *testing.T, dir string) *CSVWriter {
+	t.Helper()
+
+	w, err := NewCSVWriter(dir, "test-", WithCSVLogger(zap.NewNop()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return w
+}
+
+func readCSVTestFile(t *testing.T, path string) [][]string {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return records
+}
+
+func TestCSVWriterHeaderOnce(t *testing.T) {
+	dir := t.TempDir()
+	intervalEnd := time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC)
+
+	// restarted and appended to the same file
+	for i := 0; i < 2; i++ {
+		w := newCSVTestWriter(t, dir)
+		if err := w.Write([]stat{&QueryStat{IntervalEnd: intervalEnd, Text: "SELECT 1", TextFingerprint: int64(i)}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records := readCSVTestFile(t, filepath.Join(dir, "test-query-2021-11-01.csv"))
+	if len(records) != 3 {
+		t.Fatalf("unexpected records: %q", records)
+	}
+	if !reflect.DeepEqual(records[0][:4], []string{"interval_end", "text", "text_truncated", "text_fingerprint"}) {
+		t.Errorf("unexpected header: %q", records[0])
+	}
+	if records[1][3] != "0" || records[2][3] != "1" {
+		t.Errorf("unexpected rows: %q", records[1:])
+	}
+}
+
+func TestCSVWriterRollover(t *testing.T) {
+	dir := t.TempDir()
+	w := newCSVTestWriter(t, dir)
+
+	day := time.Date(2021, 11, 1, 23, 59, 0, 0, time.UTC)
+	err := w.Write([]stat{
+		&QueryStat{IntervalEnd: day, TextFingerprint: 1},
+		&TransactionStat{IntervalEnd: day, Fprint: 2},
+		&QueryStat{IntervalEnd: day.Add(time.Minute), TextFingerprint: 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range paths {
+		names = append(names, filepath.Base(p))
+	}
+	sort.Strings(names)
+
+	want := []string{"test-query-2021-11-01.csv", "test-query-2021-11-02.csv", "test-transaction-2021-11-01.csv"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("unexpected files: %q", names)
+	}
+	for _, name := range want {
+		if records := readCSVTestFile(t, filepath.Join(dir, name)); len(records) != 2 {
+			t.Errorf("unexpected records of %s: %q", name, records)
+		}
+	}
+}
+
+func TestCSVWriterEncoding(t *testing.T) {
+	dir := t.TempDir()
+	w := newCSVTestWriter(t, dir)
+
+	collectedAt := time.Date(2021, 11, 1, 10, 0, 0, 123456789, time.FixedZone("JST", 9*60*60))
+	err := w.Write([]stat{
+		&OldestActiveQueryStat{CollectedAt: collectedAt, Text: "SELECT 1", Elapsed: 1500 * time.Millisecond},
+		&TransactionStat{IntervalEnd: collectedAt, Fprint: 1, ReadColumns: []string{"Singers.Name"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// flushed on Close
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	query := readCSVTestFile(t, filepath.Join(dir, "test-oldest_active_query-2021-11-01.csv"))
+	if len(query) != 2 {
+		t.Fatalf("unexpected records: %q", query)
+	}
+	row := map[string]string{}
+	for i, name := range query[0] {
+		row[name] = query[1][i]
+	}
+	if row["collected_at"] != "2021-11-01T01:00:00.123456789Z" {
+		t.Errorf("unexpected time: %s", row["collected_at"])
+	}
+	if row["start_time"] != "0001-01-01T00:00:00Z" {
+		t.Errorf("unexpected zero time: %s", row["start_time"])
+	}
+	if row["elapsed"] != "1500000000" {
+		t.Errorf("unexpected duration: %s", row["elapsed"])
+	}
+
+	transaction := readCSVTestFile(t, filepath.Join(dir, "test-transaction-2021-11-01.csv"))
+	if len(transaction) != 2 {
+		t.Fatalf("unexpected records: %q", transaction)
+	}
+	row = map[string]string{}
+	for i, name := range transaction[0] {
+		row[name] = transaction[1][i]
+	}
+	if row["read_columns"] != `["Singers.Name"]` {
+		t.Errorf("unexpected array: %s", row["read_columns"])
+	}
+	// nil slices are not null
+	if row["write_constructive_columns"] != "[]" || row["write_delete_tables"] != "[]" {
+		t.Errorf("unexpected nil arrays: %s, %s", row["write_constructive_columns"], row["write_delete_tables"])
+	}
+}
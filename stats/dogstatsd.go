@@ -0,0 +1,293 @@
+package stats
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// dogStatsdUDPPacketSize is recommended not to be fragmented on the most networks
+	dogStatsdUDPPacketSize = 1432
+	dogStatsdUDSPacketSize = 8192
+	dogStatsdMaxTagLength  = 200
+)
+
+type dogStatsdWriter struct {
+	conn       net.Conn
+	packetSize int
+	prefix     string
+	textLength int
+	tags       []string
+	mu         sync.Mutex
+}
+
+type dogStatsdMetric struct {
+	name  string
+	value float64
+}
+
+// NewDogStatsdWriter return new Writer which sends gauges to DogStatsD.
+// rawURL is "udp://host:port" or "unix:///path/to/dsd.socket".
+// Query texts are never sent as is. The fingerprint and the hash of the text are sent as tags,
+// and the sanitized text truncated to textLength is sent too if textLength is positive.
+func NewDogStatsdWriter(rawURL string, prefix string, textLength int, tags []string) (Writer, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse dogstatsd URL: %w", err)
+	}
+
+	var (
+		conn       net.Conn
+		packetSize int
+	)
+
+	switch u.Scheme {
+	case "udp":
+		conn, err = net.Dial("udp", u.Host)
+		packetSize = dogStatsdUDPPacketSize
+	case "unix":
+		conn, err = net.Dial("unixgram", u.Path)
+		packetSize = dogStatsdUDSPacketSize
+	default:
+		return nil, fmt.Errorf("unexpected dogstatsd URL scheme: %s", u.Scheme)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect dogstatsd: %w", err)
+	}
+
+	sanitized := make([]string, 0, len(tags))
+	for _, t := range tags {
+		sanitized = append(sanitized, sanitizeDogStatsdTag(t))
+	}
+
+	return &dogStatsdWriter{
+		conn:       conn,
+		packetSize: packetSize,
+		prefix:     strings.TrimSuffix(prefix, "."),
+		textLength: textLength,
+		tags:       sanitized,
+	}, nil
+}
+
+func (w *dogStatsdWriter) Write(stats []stat) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	buf := &bytes.Buffer{}
+	for _, s := range stats {
+		name, tags, metrics := w.getMetrics(s)
+		if name == "" {
+			continue
+		}
+
+		// the tag section is omitted without tags, like the total stats without global tags
+		tagSection := ""
+		if allTags := append(append([]string{}, w.tags...), tags...); len(allTags) > 0 {
+			tagSection = "|#" + strings.Join(allTags, ",")
+		}
+		for _, m := range metrics {
+			line := fmt.Sprintf(
+				"%s.%s.%s:%s|g%s",
+				w.prefix,
+				name,
+				m.name,
+				strconv.FormatFloat(m.value, 'f', -1, 64),
+				tagSection,
+			)
+
+			if buf.Len() > 0 && buf.Len()+1+len(line) > w.packetSize {
+				if err := w.flush(buf); err != nil {
+					return err
+				}
+			}
+			if buf.Len() > 0 {
+				buf.WriteByte('\n')
+			}
+			buf.WriteString(line)
+		}
+	}
+
+	return w.flush(buf)
+}
+
+func (w *dogStatsdWriter) flush(buf *bytes.Buffer) error {
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	_, err := w.conn.Write(buf.Bytes())
+	buf.Reset()
+	if err != nil {
+		return fmt.Errorf("failed to send dogstatsd metrics: %w", err)
+	}
+
+	return nil
+}
+
+// textTags returns the tags which identify the query text without sending it as is
+func (w *dogStatsdWriter) textTags(fingerprint int64, text string) []string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(text))
+
+	tags := []string{
+		"fingerprint:" + strconv.FormatInt(fingerprint, 10),
+		"query_hash:" + strconv.FormatUint(h.Sum64(), 16),
+	}
+
+	if w.textLength > 0 {
+		t := sanitizeDogStatsdTagValue(text)
+		if utf8.RuneCountInString(t) > w.textLength {
+			t = string([]rune(t)[:w.textLength])
+		}
+		if t != "" {
+			tags = append(tags, sanitizeDogStatsdTag("text:"+t))
+		}
+	}
+
+	return tags
+}
+
+func (w *dogStatsdWriter) getMetrics(s stat) (string, []string, []dogStatsdMetric) {
+	switch s := s.(type) {
+	case *QueryStat:
+		return "query", w.textTags(s.TextFingerprint, s.Text), []dogStatsdMetric{
+			{"execution_count", float64(s.ExecutionCount)},
+			{"avg_latency_seconds", s.AvgLatencySeconds},
+			{"avg_rows", s.AvgRows},
+			{"avg_bytes", s.AvgBytes},
+			{"avg_rows_scanned", s.AvgRowsScanned},
+			{"avg_cpu_seconds", s.AvgCPUSeconds},
+		}
+
+	case *TransactionStat:
+		return "transaction", []string{"fprint:" + strconv.FormatInt(s.Fprint, 10)}, []dogStatsdMetric{
+			{"commit_attempt_count", float64(s.CommitAttemptCount)},
+			{"commit_failed_precondition_count", float64(s.CommitFailedPreconditionCount)},
+			{"commit_abort_count", float64(s.CommitAbortCount)},
+			{"avg_participants", s.AvgParticipants},
+			{"avg_total_latency_seconds", s.AvgTotalLatencySeconds},
+			{"avg_commit_latency_seconds", s.AvgCommitLatencySeconds},
+			{"avg_bytes", s.AvgBytes},
+		}
+
+	case *LockStat:
+		return "lock", []string{sanitizeDogStatsdTag("row_range_start_key:" + sanitizeDogStatsdTagValue(string(s.RowRangeStartKey)))}, []dogStatsdMetric{
+			{"lock_wait_seconds", s.LockWaitSeconds},
+		}
+
+	case *ReadStat:
+		return "read", []string{"fprint:" + strconv.FormatInt(s.Fprint, 10)}, []dogStatsdMetric{
+			{"execution_count", float64(s.ExecutionCount)},
+			{"avg_rows", s.AvgRows},
+			{"avg_bytes", s.AvgBytes},
+			{"avg_cpu_seconds", s.AvgCPUSeconds},
+			{"avg_locking_delay_seconds", s.AvgLockingDelaySeconds},
+			{"avg_client_wait_seconds", s.AvgClientWaitSeconds},
+			{"avg_leader_refresh_delay_seconds", s.AvgLeaderRefreshDelaySeconds},
+		}
+
+	case *QueryTotalStat:
+		return "query_total", nil, []dogStatsdMetric{
+			{"execution_count", float64(s.ExecutionCount)},
+			{"avg_latency_seconds", s.AvgLatencySeconds},
+			{"avg_rows", s.AvgRows},
+			{"avg_bytes", s.AvgBytes},
+			{"avg_rows_scanned", s.AvgRowsScanned},
+			{"avg_cpu_seconds", s.AvgCPUSeconds},
+		}
+
+	case *TransactionTotalStat:
+		return "transaction_total", nil, []dogStatsdMetric{
+			{"commit_attempt_count", float64(s.CommitAttemptCount)},
+			{"commit_failed_precondition_count", float64(s.CommitFailedPreconditionCount)},
+			{"commit_abort_count", float64(s.CommitAbortCount)},
+			{"avg_participants", s.AvgParticipants},
+			{"avg_total_latency_seconds", s.AvgTotalLatencySeconds},
+			{"avg_commit_latency_seconds", s.AvgCommitLatencySeconds},
+			{"avg_bytes", s.AvgBytes},
+		}
+
+	case *LockTotalStat:
+		return "lock_total", nil, []dogStatsdMetric{
+			{"total_lock_wait_seconds", s.TotalLockWaitSeconds},
+		}
+
+	case *TableSizeStat:
+		return "table_size", []string{sanitizeDogStatsdTag("table_name:" + s.TableName)}, []dogStatsdMetric{
+			{"used_bytes", s.UsedBytes},
+		}
+
+	case *OldestActiveQueryStat:
+		return "oldest_active_query", w.textTags(s.TextFingerprint, s.Text), []dogStatsdMetric{
+			{"elapsed_seconds", s.Elapsed.Seconds()},
+		}
+
+	case *ActivePartitionedDMLStat:
+		return "active_partitioned_dml",
+			append(w.textTags(s.TextFingerprint, s.Text), "completed:"+strconv.FormatBool(s.Completed)),
+			[]dogStatsdMetric{
+				{"num_partitions_total", float64(s.NumPartitionsTotal)},
+				{"num_partitions_complete", float64(s.NumPartitionsComplete)},
+				{"progress", s.Progress},
+				{"rows_processed", float64(s.RowsProcessed)},
+				{"elapsed_seconds", s.Elapsed.Seconds()},
+			}
+
+	case *CollectorErrorStat:
+		return "collector", []string{sanitizeDogStatsdTag("family:" + s.Family)}, []dogStatsdMetric{
+			{"errors", float64(s.ErrorCount)},
+		}
+	}
+
+	return "", nil, nil
+}
+
+// sanitizeDogStatsdTagValue replaces the characters which are not allowed in tags with underscores.
+// See: https://docs.datadoghq.com/getting_started/tagging/#define-tags
+func sanitizeDogStatsdTagValue(value string) string {
+	b := strings.Builder{}
+	lastUnderscore := false
+	for _, r := range strings.ToLower(value) {
+		allowed := (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') ||
+			r == '-' || r == '.' || r == '/' || r == '_'
+		if !allowed {
+			r = '_'
+		}
+		if r == '_' {
+			if lastUnderscore {
+				continue
+			}
+			lastUnderscore = true
+		} else {
+			lastUnderscore = false
+		}
+		b.WriteRune(r)
+	}
+
+	return strings.Trim(b.String(), "_")
+}
+
+// sanitizeDogStatsdTag makes "key:value" valid as the tag
+func sanitizeDogStatsdTag(tag string) string {
+	key, value := tag, ""
+	if i := strings.Index(tag, ":"); i >= 0 {
+		key, value = tag[:i], tag[i+1:]
+	}
+
+	result := sanitizeDogStatsdTagValue(key)
+	if value != "" {
+		result += ":" + sanitizeDogStatsdTagValue(value)
+	}
+	if len(result) > dogStatsdMaxTagLength {
+		result = result[:dogStatsdMaxTagLength]
+	}
+
+	return result
+}
@@ -0,0 +1,74 @@
+package stats
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDogStatsdWriter(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	tests := []struct {
+		name  string
+		tags  []string
+		stats []stat
+		want  []string
+	}{
+		{
+			name: "query",
+			stats: []stat{
+				&QueryStat{Text: "SELECT 1", TextFingerprint: 1, ExecutionCount: 10},
+				&QueryStat{Text: "SELECT 2", TextFingerprint: 2, ExecutionCount: 20},
+			},
+			want: []string{
+				"spanner.stats.query.execution_count:10|g|#fingerprint:1,query_hash:",
+				"spanner.stats.query.execution_count:20|g|#fingerprint:2,query_hash:",
+			},
+		},
+		{
+			name:  "total without tags",
+			stats: []stat{&QueryTotalStat{ExecutionCount: 30}},
+			want:  []string{"spanner.stats.query_total.execution_count:30|g\n"},
+		},
+		{
+			name:  "total with global tags",
+			tags:  []string{"env:Production"},
+			stats: []stat{&LockTotalStat{TotalLockWaitSeconds: 1.5}},
+			want:  []string{"spanner.stats.lock_total.total_lock_wait_seconds:1.5|g|#env:production\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := NewDogStatsdWriter("udp://"+conn.LocalAddr().String(), "spanner.stats.", 0, tt.tags)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Write(tt.stats); err != nil {
+				t.Fatal(err)
+			}
+
+			buf := make([]byte, dogStatsdUDSPacketSize)
+			_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// each line ends with a newline to match the end of the line
+			packet := string(buf[:n]) + "\n"
+			for _, want := range tt.want {
+				if !strings.Contains(packet, want) {
+					t.Errorf("missing %q in:\n%s", want, packet)
+				}
+			}
+		})
+	}
+}
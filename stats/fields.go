@@ -0,0 +1,117 @@
+package stats
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// statField is a pair of the field name and the value of the stat struct
+type statField struct {
+	name  string
+	value interface{}
+}
+
+// statKeyFields are the fields which identify the row of the interval stats in the same IntervalEnd
+var statKeyFields = map[string][]string{
+	"QueryStat":            {"IntervalEnd", "TextFingerprint"},
+	"TransactionStat":      {"IntervalEnd", "Fprint"},
+	"LockStat":             {"IntervalEnd", "RowRangeStartKey"},
+	"ReadStat":             {"IntervalEnd", "Fprint"},
+	"QueryTotalStat":       {"IntervalEnd"},
+	"TransactionTotalStat": {"IntervalEnd"},
+	"LockTotalStat":        {"IntervalEnd"},
+	"TableSizeStat":        {"IntervalEnd", "TableName"},
+}
+
+// intervalStats are the zero values of the interval stats, to derive the schemas
+var intervalStats = []stat{
+	&QueryStat{},
+	&TransactionStat{},
+	&LockStat{},
+	&ReadStat{},
+	&QueryTotalStat{},
+	&TransactionTotalStat{},
+	&LockTotalStat{},
+	&TableSizeStat{},
+}
+
+// snapshotStats are the zero values of the snapshot stats
+var snapshotStats = []stat{
+	&OldestActiveQueryStat{},
+	&ActivePartitionedDMLStat{},
+	&CollectorErrorStat{},
+}
+
+// statFingerprintFields are the fields which identify the query or the transaction
+var statFingerprintFields = []string{"TextFingerprint", "Fprint"}
+
+// statTypeName returns the struct name of the stat like "QueryStat"
+func statTypeName(s stat) string {
+	return reflect.Indirect(reflect.ValueOf(s)).Type().Name()
+}
+
+// statFamilyName returns the snake_case name of the stat family like "query" or "transaction_total"
+func statFamilyName(s stat) string {
+	return toSnakeCase(strings.TrimSuffix(statTypeName(s), "Stat"))
+}
+
+// statFields returns the exported fields of the stat in the order of the struct.
+// It is for the writers which don't need to know each stat type.
+func statFields(s stat) []statField {
+	v := reflect.Indirect(reflect.ValueOf(s))
+	t := v.Type()
+
+	fields := make([]statField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath != "" {
+			continue
+		}
+		fields = append(fields, statField{
+			name:  t.Field(i).Name,
+			value: v.Field(i).Interface(),
+		})
+	}
+
+	return fields
+}
+
+// toSnakeCase converts the field name like "AvgCPUSeconds" to "avg_cpu_seconds"
+func toSnakeCase(name string) string {
+	runes := []rune(name)
+	b := strings.Builder{}
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			prevLower := i > 0 && !unicode.IsUpper(runes[i-1])
+			nextLower := i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || nextLower {
+				b.WriteRune('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// statFieldValue returns the value of the exported field, or nil if the stat doesn't have it
+func statFieldValue(s stat, name string) interface{} {
+	v := reflect.Indirect(reflect.ValueOf(s)).FieldByName(name)
+	if !v.IsValid() || !v.CanInterface() {
+		return nil
+	}
+
+	return v.Interface()
+}
+
+// statFingerprint returns TextFingerprint or Fprint of the stat
+func statFingerprint(s stat) (int64, bool) {
+	for _, name := range statFingerprintFields {
+		if f, ok := statFieldValue(s, name).(int64); ok {
+			return f, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,199 @@
+package stats
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"sync"
+	"time"
+)
+
+type filterWriter struct {
+	writer Writer
+	rules  []func(stat) bool
+
+	sampleRate float64
+	mu         sync.Mutex
+	rand       *rand.Rand
+
+	// err is the invalid option, returned by NewFilterWriter
+	err error
+}
+
+// FilterOption configures the filter of NewFilterWriter
+type FilterOption func(*filterWriter)
+
+// WithFilterTypes keeps only the stats of the types like "QueryStat"
+func WithFilterTypes(types ...string) FilterOption {
+	allowed := map[string]bool{}
+	for _, t := range types {
+		allowed[t] = true
+	}
+
+	return func(w *filterWriter) {
+		w.rules = append(w.rules, func(s stat) bool {
+			return allowed[statTypeName(s)]
+		})
+	}
+}
+
+// WithFilterIncludeText keeps only the stats whose Text matches. The stats without Text are kept.
+func WithFilterIncludeText(re *regexp.Regexp) FilterOption {
+	return func(w *filterWriter) {
+		w.rules = append(w.rules, func(s stat) bool {
+			text, ok := statFieldValue(s, "Text").(string)
+			return !ok || re.MatchString(text)
+		})
+	}
+}
+
+// WithFilterExcludeText drops the stats whose Text matches, like health check queries "^SELECT 1$"
+func WithFilterExcludeText(re *regexp.Regexp) FilterOption {
+	return func(w *filterWriter) {
+		w.rules = append(w.rules, func(s stat) bool {
+			text, ok := statFieldValue(s, "Text").(string)
+			return !ok || !re.MatchString(text)
+		})
+	}
+}
+
+// WithFilterAllowFingerprints keeps only the stats of the fingerprints. The stats without fingerprint are kept.
+func WithFilterAllowFingerprints(fingerprints ...int64) FilterOption {
+	allowed := map[int64]bool{}
+	for _, f := range fingerprints {
+		allowed[f] = true
+	}
+
+	return func(w *filterWriter) {
+		w.rules = append(w.rules, func(s stat) bool {
+			f, ok := statFingerprint(s)
+			return !ok || allowed[f]
+		})
+	}
+}
+
+// WithFilterDenyFingerprints drops the stats of the fingerprints
+func WithFilterDenyFingerprints(fingerprints ...int64) FilterOption {
+	denied := map[int64]bool{}
+	for _, f := range fingerprints {
+		denied[f] = true
+	}
+
+	return func(w *filterWriter) {
+		w.rules = append(w.rules, func(s stat) bool {
+			f, ok := statFingerprint(s)
+			return !ok || !denied[f]
+		})
+	}
+}
+
+// WithFilterThreshold keeps only the stats whose field like "AvgCPUSeconds" is greater than min.
+// The stats without the field are kept. Durations are compared in seconds.
+// NewFilterWriter returns an error if no stat has the numeric field, like a typo.
+func WithFilterThreshold(field string, min float64) FilterOption {
+	return func(w *filterWriter) {
+		if !isFilterThresholdField(field) {
+			w.err = fmt.Errorf("unknown threshold field: %s", field)
+			return
+		}
+
+		w.rules = append(w.rules, func(s stat) bool {
+			switch v := statFieldValue(s, field).(type) {
+			case int64:
+				return float64(v) > min
+			case float64:
+				return v > min
+			case time.Duration:
+				return v.Seconds() > min
+			}
+			return true
+		})
+	}
+}
+
+// isFilterThresholdField returns true if any stat has the field of int64, float64 or time.Duration
+func isFilterThresholdField(field string) bool {
+	for _, s := range append(append([]stat{}, intervalStats...), snapshotStats...) {
+		switch statFieldValue(s, field).(type) {
+		case int64, float64, time.Duration:
+			return true
+		}
+	}
+
+	return false
+}
+
+// WithFilterSampleRate keeps the stats randomly at the rate between 0 and 1, after the other rules
+func WithFilterSampleRate(rate float64) FilterOption {
+	return func(w *filterWriter) {
+		w.sampleRate = rate
+	}
+}
+
+// NewFilterWriter return new Writer which writes only the stats matching all rules to the writer.
+// It can be nested to combine the rules for the different sinks.
+// The events, CollectorErrorStat and the completed ActivePartitionedDMLStat, are always written,
+// because they are not samples and dropping them hides the failures and the completions.
+func NewFilterWriter(writer Writer, opts ...FilterOption) (Writer, error) {
+	w := &filterWriter{
+		writer:     writer,
+		sampleRate: 1,
+		rand:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.err != nil {
+		return nil, w.err
+	}
+
+	return w, nil
+}
+
+func (w *filterWriter) Write(stats []stat) error {
+	filtered := make([]stat, 0, len(stats))
+	for _, s := range stats {
+		if w.keep(s) {
+			filtered = append(filtered, s)
+		}
+	}
+	if len(filtered) == 0 {
+		return nil
+	}
+
+	return w.writer.Write(filtered)
+}
+
+func (w *filterWriter) keep(s stat) bool {
+	if isFilterEvent(s) {
+		return true
+	}
+
+	for _, rule := range w.rules {
+		if !rule(s) {
+			return false
+		}
+	}
+
+	if w.sampleRate >= 1 {
+		return true
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.rand.Float64() < w.sampleRate
+}
+
+// isFilterEvent returns true if the stat is the event which is not filtered
+func isFilterEvent(s stat) bool {
+	switch s := s.(type) {
+	case *CollectorErrorStat:
+		return true
+	case *ActivePartitionedDMLStat:
+		return s.Completed
+	}
+
+	return false
+}
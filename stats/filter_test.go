@@ -0,0 +1,116 @@
+package stats
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestFilterWriterFingerprints(t *testing.T) {
+	stats := []stat{
+		&QueryStat{Text: "SELECT 1", TextFingerprint: 1},
+		&QueryStat{Text: "SELECT 2", TextFingerprint: 2},
+		&TransactionStat{Fprint: 3},
+		&QueryTotalStat{ExecutionCount: 10},
+	}
+
+	tests := []struct {
+		name string
+		opt  FilterOption
+		want []stat
+	}{
+		{
+			name: "allow",
+			opt:  WithFilterAllowFingerprints(1, 3),
+			want: []stat{stats[0], stats[2], stats[3]},
+		},
+		{
+			name: "deny",
+			opt:  WithFilterDenyFingerprints(1, 3),
+			want: []stat{stats[1], stats[3]},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &failingWriter{}
+			w, err := NewFilterWriter(out, tt.opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := w.Write(stats); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(out.written, tt.want) {
+				t.Errorf("unexpected stats: %+v", out.written)
+			}
+		})
+	}
+}
+
+func TestFilterWriterThreshold(t *testing.T) {
+	out := &failingWriter{}
+	w, err := NewFilterWriter(
+		out,
+		WithFilterThreshold("AvgCPUSeconds", 0.5),
+		WithFilterThreshold("Elapsed", 1),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := []stat{
+		&QueryStat{TextFingerprint: 1, AvgCPUSeconds: 1},
+		&QueryStat{TextFingerprint: 2, AvgCPUSeconds: 0.1},
+		&OldestActiveQueryStat{TextFingerprint: 3, Elapsed: 2 * time.Second},
+		&OldestActiveQueryStat{TextFingerprint: 4, Elapsed: time.Second / 2},
+	}
+	if err := w.Write(stats); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []stat{stats[0], stats[2]}
+	if !reflect.DeepEqual(out.written, want) {
+		t.Errorf("unexpected stats: %+v", out.written)
+	}
+}
+
+func TestFilterWriterUnknownThresholdField(t *testing.T) {
+	for _, field := range []string{"AvgCpuSeconds", "Text", ""} {
+		if _, err := NewFilterWriter(&failingWriter{}, WithFilterThreshold(field, 1)); err == nil {
+			t.Errorf("no error for %q", field)
+		}
+	}
+}
+
+func TestFilterWriterKeepsEvents(t *testing.T) {
+	out := &failingWriter{}
+	w, err := NewFilterWriter(
+		out,
+		WithFilterTypes("QueryStat"),
+		WithFilterExcludeText(regexp.MustCompile("UPDATE")),
+		WithFilterThreshold("Progress", 2),
+		WithFilterSampleRate(0),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := []stat{
+		&CollectorErrorStat{Family: "query/minute", ErrorCount: 1},
+		&ActivePartitionedDMLStat{Text: "UPDATE t SET x = 1 WHERE true", TextFingerprint: 1, Completed: true},
+		// the running ones are samples
+		&ActivePartitionedDMLStat{Text: "UPDATE t SET x = 1 WHERE true", TextFingerprint: 1},
+		&QueryStat{Text: "SELECT 1", TextFingerprint: 2},
+	}
+	if err := w.Write(stats); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []stat{stats[0], stats[1]}
+	if !reflect.DeepEqual(out.written, want) {
+		t.Errorf("unexpected stats: %+v", out.written)
+	}
+}
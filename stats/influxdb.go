@@ -0,0 +1,250 @@
+package stats
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	influxDBUDPPacketSize    = 1432
+	influxDBDefaultBatchSize = 5000
+)
+
+// influxDBTagFields are the fields written as tags. They identify the point in the same IntervalEnd,
+// and other fields are written as fields.
+var influxDBTagFields = map[string][]string{
+	"QueryStat":                {"TextFingerprint"},
+	"TransactionStat":          {"Fprint"},
+	"LockStat":                 {"RowRangeStartKey"},
+	"ReadStat":                 {"Fprint"},
+	"TableSizeStat":            {"TableName"},
+	"OldestActiveQueryStat":    {"SessionID", "TextFingerprint"},
+	"ActivePartitionedDMLStat": {"SessionID", "TextFingerprint", "Completed"},
+	"CollectorErrorStat":       {"Family"},
+}
+
+// influxDBTimestampFields are used as the point timestamp
+var influxDBTimestampFields = map[string]bool{
+	"IntervalEnd": true,
+	"CollectedAt": true,
+}
+
+type influxDBWriter struct {
+	url       *url.URL
+	headers   map[string]string
+	batchSize int
+	client    *http.Client
+	conn      net.Conn
+	prefix    string
+}
+
+// NewInfluxDBWriter return new Writer which writes stats as InfluxDB line protocol.
+// rawURL is the HTTP write endpoint like "http://localhost:8086/api/v2/write?org=xxx&bucket=yyy"
+// or "http://localhost:8086/write?db=xxx", or "udp://localhost:8089".
+// The point timestamp is IntervalEnd of the stat, so the backfilled stats land at the right time.
+func NewInfluxDBWriter(rawURL string, prefix string, headers map[string]string, batchSize int, client *http.Client) (Writer, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse influxdb URL: %w", err)
+	}
+	if batchSize <= 0 {
+		batchSize = influxDBDefaultBatchSize
+	}
+
+	w := &influxDBWriter{
+		url:       u,
+		headers:   headers,
+		batchSize: batchSize,
+		client:    client,
+		prefix:    prefix,
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+	case "udp":
+		w.conn, err = net.Dial("udp", u.Host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect influxdb: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unexpected influxdb URL scheme: %s", u.Scheme)
+	}
+
+	return w, nil
+}
+
+func (w *influxDBWriter) Write(stats []stat) error {
+	lines := make([]string, 0, len(stats))
+	for _, s := range stats {
+		lines = append(lines, w.line(s))
+	}
+
+	for sent := 0; sent < len(lines); {
+		n := w.batchSize
+		if n > len(lines)-sent {
+			n = len(lines) - sent
+		}
+
+		if err := w.send(lines[sent : sent+n]); err != nil {
+			return &PartialWriteError{Written: sent, Err: fmt.Errorf("failed to write influxdb: %w", err)}
+		}
+		sent += n
+	}
+
+	return nil
+}
+
+func (w *influxDBWriter) send(lines []string) error {
+	if w.conn != nil {
+		return w.sendUDP(lines)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.url.String(), strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	for k, v := range w.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status: %s: %s", resp.Status, body)
+	}
+
+	return nil
+}
+
+func (w *influxDBWriter) sendUDP(lines []string) error {
+	buf := &bytes.Buffer{}
+	flush := func() error {
+		if buf.Len() == 0 {
+			return nil
+		}
+		_, err := w.conn.Write(buf.Bytes())
+		buf.Reset()
+		return err
+	}
+
+	for _, line := range lines {
+		if buf.Len() > 0 && buf.Len()+1+len(line) > influxDBUDPPacketSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(line)
+	}
+
+	return flush()
+}
+
+// line renders the stat as "measurement,tag=value field=value timestamp"
+func (w *influxDBWriter) line(s stat) string {
+	typeName := statTypeName(s)
+
+	isTag := map[string]bool{}
+	for _, name := range influxDBTagFields[typeName] {
+		isTag[name] = true
+	}
+
+	var tags, fields []string
+	for _, f := range statFields(s) {
+		if influxDBTimestampFields[f.name] {
+			continue
+		}
+
+		key := influxDBEscapeKey(toSnakeCase(f.name))
+		if isTag[f.name] {
+			v := influxDBEscapeKey(influxDBTagValue(f.value))
+			if v != "" {
+				tags = append(tags, key+"="+v)
+			}
+			continue
+		}
+		fields = append(fields, key+"="+influxDBFieldValue(f.value))
+	}
+
+	measurement := influxDBEscapeMeasurement(w.prefix + toSnakeCase(typeName))
+	if len(tags) > 0 {
+		measurement += "," + strings.Join(tags, ",")
+	}
+
+	return fmt.Sprintf("%s %s %d", measurement, strings.Join(fields, ","), s.getIntervalEnd().UnixNano())
+}
+
+var (
+	influxDBMeasurementReplacer = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", " ", "\r", " ", "\t", " ")
+	influxDBKeyReplacer         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", " ", "\r", " ", "\t", " ")
+	influxDBStringReplacer      = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", " ", "\r", " ")
+)
+
+func influxDBEscapeMeasurement(s string) string {
+	return influxDBMeasurementReplacer.Replace(s)
+}
+
+func influxDBEscapeKey(s string) string {
+	// a trailing backslash escapes the following separator
+	return strings.TrimRight(influxDBKeyReplacer.Replace(s), `\`)
+}
+
+func influxDBTagValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+
+	return fmt.Sprint(value)
+}
+
+func influxDBFieldValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return `"` + influxDBStringReplacer.Replace(v) + `"`
+	case []byte:
+		return `"` + influxDBStringReplacer.Replace(string(v)) + `"`
+	case []string:
+		return `"` + influxDBStringReplacer.Replace(strings.Join(v, ",")) + `"`
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10) + "i"
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		return strconv.FormatInt(v.UnixNano(), 10) + "i"
+	case time.Duration:
+		return strconv.FormatInt(int64(v), 10) + "i"
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		b = []byte(fmt.Sprint(value))
+	}
+
+	return `"` + influxDBStringReplacer.Replace(string(b)) + `"`
+}
@@ -0,0 +1,89 @@
+package stats
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInfluxDBWriter(t *testing.T) {
+	var (
+		body   string
+		header string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+		header = r.Header.Get("Authorization")
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	w, err := NewInfluxDBWriter(server.URL+"/write?db=test", "spanner_", map[string]string{"Authorization": "Token xxx"}, 0, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	intervalEnd := time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC)
+	err = w.Write([]stat{
+		&QueryStat{IntervalEnd: intervalEnd, Text: "SELECT 1", TextFingerprint: 1, ExecutionCount: 10},
+		&QueryStat{IntervalEnd: intervalEnd, Text: "SELECT 2", TextFingerprint: 2, ExecutionCount: 20},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if header != "Token xxx" {
+		t.Errorf("unexpected header: %q", header)
+	}
+
+	// the queries in the same interval are the different series, otherwise only one point is kept
+	lines := strings.Split(body, "\n")
+	if len(lines) != 2 {
+		t.Fatalf("unexpected lines: %q", lines)
+	}
+	for i, prefix := range []string{
+		"spanner_query_stat,text_fingerprint=1 ",
+		"spanner_query_stat,text_fingerprint=2 ",
+	} {
+		if !strings.HasPrefix(lines[i], prefix) {
+			t.Errorf("line %d doesn't start with %q: %s", i, prefix, lines[i])
+		}
+		if !strings.HasSuffix(lines[i], " 1635760800000000000") {
+			t.Errorf("line %d doesn't end with IntervalEnd: %s", i, lines[i])
+		}
+	}
+}
+
+func TestInfluxDBWriterPartialWrite(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests > 1 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	w, err := NewInfluxDBWriter(server.URL+"/write?db=test", "spanner_", nil, 2, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first batch of two is written before the failure
+	err = w.Write([]stat{
+		&QueryStat{TextFingerprint: 1},
+		&QueryStat{TextFingerprint: 2},
+		&QueryStat{TextFingerprint: 3},
+	})
+	var partial *PartialWriteError
+	if !errors.As(err, &partial) || partial.Written != 2 {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
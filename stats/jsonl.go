@@ -0,0 +1,201 @@
+package stats
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// JSONLSchemaVersion is written into each line as "schema_version".
+// Bump it when the meaning of existing keys is changed.
+const JSONLSchemaVersion = 1
+
+// JSONLWriterConfig is the configuration of JSONLWriter
+type JSONLWriterConfig struct {
+	// Dir to write files into
+	Dir string
+	// Prefix of the file names
+	Prefix string
+	// MaxBytes rotates the file when the uncompressed size exceeds it. 0 disables.
+	MaxBytes int64
+	// MaxAge rotates the file when it has been opened longer than it. 0 disables.
+	MaxAge time.Duration
+	// Gzip compresses the files
+	Gzip bool
+	// Logger for the skipped stats. nil logs to stderr.
+	Logger *zap.Logger
+}
+
+// JSONLWriter writes one JSON object per stat into rotating files, without log envelope fields.
+// Each object has "schema_version", "type" and the fields of the stat in snake_case.
+type JSONLWriter struct {
+	cfg JSONLWriterConfig
+
+	mu       sync.Mutex
+	file     *os.File
+	gzip     *gzip.Writer
+	buf      *bufio.Writer
+	written  int64
+	openedAt time.Time
+}
+
+// NewJSONLWriter return new JSONLWriter. Close it to flush the last file.
+func NewJSONLWriter(cfg JSONLWriterConfig) (*JSONLWriter, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if cfg.Logger == nil {
+		cfg.Logger = newDefaultLogger()
+	}
+
+	return &JSONLWriter{
+		cfg: cfg,
+	}, nil
+}
+
+// Write stats collection as JSON lines
+func (w *JSONLWriter) Write(stats []stat) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, s := range stats {
+		line, err := marshalJSONLine(s)
+		if err != nil {
+			// writing again doesn't help, so it is skipped
+			w.cfg.Logger.Error("failed to marshal stat", zap.String("type", statTypeName(s)), zap.Error(err))
+			continue
+		}
+
+		if err := w.rotateIfNeeded(); err != nil {
+			return fmt.Errorf("failed to rotate jsonl file: %w", err)
+		}
+
+		n, err := w.buf.Write(line)
+		w.written += int64(n)
+		if err != nil {
+			return fmt.Errorf("failed to write jsonl file: %w", err)
+		}
+	}
+
+	if w.buf != nil {
+		if err := w.buf.Flush(); err != nil {
+			return fmt.Errorf("failed to write jsonl file: %w", err)
+		}
+	}
+	// the complete blocks can be read from the current file, and survive crashing until rotation
+	if w.gzip != nil {
+		if err := w.gzip.Flush(); err != nil {
+			return fmt.Errorf("failed to write jsonl file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Close the current file
+func (w *JSONLWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.closeFile()
+}
+
+func (w *JSONLWriter) rotateIfNeeded() error {
+	if w.file != nil {
+		bySize := w.cfg.MaxBytes > 0 && w.written >= w.cfg.MaxBytes
+		byAge := w.cfg.MaxAge > 0 && time.Since(w.openedAt) >= w.cfg.MaxAge
+		if !bySize && !byAge {
+			return nil
+		}
+
+		if err := w.closeFile(); err != nil {
+			return err
+		}
+	}
+
+	return w.openFile()
+}
+
+func (w *JSONLWriter) openFile() error {
+	now := time.Now().UTC()
+	name := fmt.Sprintf("%s%s.jsonl", w.cfg.Prefix, now.Format("20060102T150405.000000000Z"))
+	if w.cfg.Gzip {
+		name += ".gz"
+	}
+
+	f, err := os.OpenFile(filepath.Join(w.cfg.Dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = f
+	if w.cfg.Gzip {
+		w.gzip = gzip.NewWriter(f)
+		out = w.gzip
+	}
+
+	w.file = f
+	w.buf = bufio.NewWriter(out)
+	w.written = 0
+	w.openedAt = now
+
+	return nil
+}
+
+func (w *JSONLWriter) closeFile() error {
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.buf.Flush()
+	if w.gzip != nil {
+		if gzErr := w.gzip.Close(); err == nil {
+			err = gzErr
+		}
+	}
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	w.file = nil
+	w.gzip = nil
+	w.buf = nil
+
+	return err
+}
+
+// marshalJSONLine renders the stat as a JSON object with the stable key order, followed by a newline.
+func marshalJSONLine(s stat) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, `{"schema_version":%d,"type":%q`, JSONLSchemaVersion, statTypeName(s))
+
+	for _, f := range statFields(s) {
+		value := f.value
+		if b, ok := value.([]byte); ok {
+			value = string(b)
+		}
+
+		v, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+
+		buf.WriteString(`,"`)
+		buf.WriteString(toSnakeCase(f.name))
+		buf.WriteString(`":`)
+		buf.Write(v)
+	}
+	buf.WriteString("}\n")
+
+	return buf.Bytes(), nil
+}
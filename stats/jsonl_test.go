@@ -0,0 +1,74 @@
+package stats
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONLWriterGzip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w, err := NewJSONLWriter(JSONLWriterConfig{
+		Dir:    dir,
+		Prefix: "test-",
+		MaxAge: 24 * time.Hour,
+		Gzip:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	err = w.Write([]stat{
+		&QueryStat{Text: "SELECT 1", TextFingerprint: 1},
+		&QueryStat{Text: "SELECT 2", TextFingerprint: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "test-*.jsonl.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("unexpected files: %v", files)
+	}
+
+	// the file is still open, so it has no gzip trailer yet
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	if len(lines) != 2 {
+		t.Fatalf("unexpected lines: %q", lines)
+	}
+	for i, want := range []string{`"text":"SELECT 1"`, `"text":"SELECT 2"`} {
+		if !strings.Contains(lines[i], want) {
+			t.Errorf("line %d doesn't contain %s: %s", i, want, lines[i])
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package stats
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+const multiWriterDefaultQueueSize = 16
+
+// MultiWriter writes stats to the multiple Writers. Each Writer has its own bounded queue and goroutine,
+// so a slow or failing Writer doesn't block the Worker and the other Writers.
+// When the queue of a Writer is full, the stats for it are dropped. Wrap each Writer with SpoolWriter
+// to keep the stats which the Writer failed to write, because MultiWriter can't report the failures.
+type MultiWriter struct {
+	sinks     []*multiWriterSink
+	queueSize int
+	logger    *zap.Logger
+	wg        sync.WaitGroup
+	once      sync.Once
+}
+
+type multiWriterSink struct {
+	writer  Writer
+	queue   chan []stat
+	dropped int64
+	logger  *zap.Logger
+}
+
+// MultiWriterOption configures the MultiWriter
+type MultiWriterOption func(*MultiWriter)
+
+// WithMultiQueueSize sets the number of Write calls queued for each Writer
+func WithMultiQueueSize(size int) MultiWriterOption {
+	return func(w *MultiWriter) {
+		if size > 0 {
+			w.queueSize = size
+		}
+	}
+}
+
+// WithMultiLogger sets the logger for the dropped stats and the errors of the Writers
+func WithMultiLogger(logger *zap.Logger) MultiWriterOption {
+	return func(w *MultiWriter) {
+		w.logger = logger
+	}
+}
+
+// NewMultiWriter return new MultiWriter of the writers. By default, 16 Write calls are queued for each Writer.
+// Close it to write the queued stats.
+func NewMultiWriter(writers []Writer, opts ...MultiWriterOption) *MultiWriter {
+	w := &MultiWriter{
+		queueSize: multiWriterDefaultQueueSize,
+		logger:    newDefaultLogger(),
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	for _, writer := range writers {
+		sink := &multiWriterSink{
+			writer: writer,
+			queue:  make(chan []stat, w.queueSize),
+			logger: w.logger,
+		}
+		w.sinks = append(w.sinks, sink)
+
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			sink.run()
+		}()
+	}
+
+	return w
+}
+
+// Write queues stats collection for each Writer. The stats dropped by the full queue are counted and logged,
+// and the errors of the Writers are logged too. Both are not returned,
+// because the caller would write the same stats again to all Writers and a slow Writer would stop the checkpoint.
+func (w *MultiWriter) Write(stats []stat) error {
+	for i, sink := range w.sinks {
+		select {
+		case sink.queue <- stats:
+		default:
+			n := atomic.AddInt64(&sink.dropped, int64(len(stats)))
+			w.logger.Warn(
+				"writer is too slow, dropped stats",
+				zap.Int("writer", i),
+				zap.String("writer_type", fmt.Sprintf("%T", sink.writer)),
+				zap.Int("dropped", len(stats)),
+				zap.Int64("total_dropped", n),
+			)
+		}
+	}
+
+	return nil
+}
+
+// Dropped returns the number of stats dropped for each Writer, in the order of NewMultiWriter
+func (w *MultiWriter) Dropped() []int64 {
+	dropped := make([]int64, 0, len(w.sinks))
+	for _, sink := range w.sinks {
+		dropped = append(dropped, atomic.LoadInt64(&sink.dropped))
+	}
+
+	return dropped
+}
+
+// Close stops accepting stats and waits until the queued stats are written. Don't Write after Close.
+func (w *MultiWriter) Close() {
+	w.once.Do(func() {
+		for _, sink := range w.sinks {
+			close(sink.queue)
+		}
+	})
+	w.wg.Wait()
+}
+
+func (s *multiWriterSink) run() {
+	for stats := range s.queue {
+		s.write(stats)
+	}
+}
+
+func (s *multiWriterSink) write(stats []stat) {
+	// a panic of a Writer must not stop the other Writers
+	defer func() {
+		if r := recover(); r != nil {
+			s.logger.Error("writer panicked", zap.String("writer_type", fmt.Sprintf("%T", s.writer)), zap.Any("panic", r))
+		}
+	}()
+
+	if err := s.writer.Write(stats); err != nil {
+		s.logger.Error("writer failed", zap.String("writer_type", fmt.Sprintf("%T", s.writer)), zap.Error(err))
+	}
+}
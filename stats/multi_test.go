@@ -0,0 +1,73 @@
+package stats
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// blockingWriter waits for release before writing, like a slow sink
+type blockingWriter struct {
+	release chan struct{}
+
+	mu      sync.Mutex
+	written int
+}
+
+func (w *blockingWriter) Write(stats []stat) error {
+	<-w.release
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.written += len(stats)
+
+	return nil
+}
+
+// recordingWriter keeps the written stats and notifies each Write
+type recordingWriter struct {
+	mu      sync.Mutex
+	written []stat
+	done    chan struct{}
+}
+
+func (w *recordingWriter) Write(stats []stat) error {
+	w.mu.Lock()
+	w.written = append(w.written, stats...)
+	w.mu.Unlock()
+
+	w.done <- struct{}{}
+
+	return nil
+}
+
+func TestMultiWriterDropped(t *testing.T) {
+	slow := &blockingWriter{release: make(chan struct{})}
+	healthy := &recordingWriter{done: make(chan struct{})}
+	w := NewMultiWriter([]Writer{slow, healthy}, WithMultiQueueSize(1), WithMultiLogger(zap.NewNop()))
+
+	// the queue of the slow writer is full at the third one at the latest
+	var want []stat
+	for i := 0; i < 5; i++ {
+		s := &QueryStat{Text: "SELECT 1", TextFingerprint: int64(i)}
+		want = append(want, s)
+
+		if err := w.Write([]stat{s}); err != nil {
+			t.Fatalf("dropped stats are returned: %s", err)
+		}
+		<-healthy.done
+	}
+
+	close(slow.release)
+	w.Close()
+
+	dropped := w.Dropped()
+	if dropped[0] < 3 || dropped[0]+int64(slow.written) != 5 || dropped[1] != 0 {
+		t.Errorf("unexpected dropped: %v, written by the slow writer: %d", dropped, slow.written)
+	}
+	if !reflect.DeepEqual(healthy.written, want) {
+		t.Errorf("the healthy writer didn't get each stat exactly once: %+v", healthy.written)
+	}
+}
@@ -0,0 +1,218 @@
+package stats
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+const otlpLogScopeName = "github.com/sters/spanner-query-stats-collector/stats"
+
+type otlpLogWriter struct {
+	url                string
+	headers            map[string]string
+	client             *http.Client
+	resourceAttributes []otlpKeyValue
+}
+
+// NewOTLPLogWriter return new Writer which sends each stat as the log record of OTLP/HTTP with JSON encoding.
+// url is the logs endpoint of the collector like "http://localhost:4318/v1/logs".
+// Unlike OpenTelemetryWriter, high-cardinality text is sent as the log body, not as metric attributes.
+func NewOTLPLogWriter(url string, headers map[string]string, client *http.Client, resourceAttributes map[string]string) Writer {
+	attrs := make([]otlpKeyValue, 0, len(resourceAttributes))
+	for k, v := range resourceAttributes {
+		attrs = append(attrs, otlpKeyValue{Key: k, Value: otlpAnyValueOf(v)})
+	}
+
+	return &otlpLogWriter{
+		url:                url,
+		headers:            headers,
+		client:             client,
+		resourceAttributes: attrs,
+	}
+}
+
+// followed https://github.com/open-telemetry/opentelemetry-proto/blob/main/opentelemetry/proto/logs/v1/logs.proto
+type otlpLogsData struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         string         `json:"timeUnixNano"`
+	ObservedTimeUnixNano string         `json:"observedTimeUnixNano"`
+	SeverityNumber       int            `json:"severityNumber"`
+	SeverityText         string         `json:"severityText"`
+	Body                 otlpAnyValue   `json:"body"`
+	Attributes           []otlpKeyValue `json:"attributes"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string          `json:"stringValue,omitempty"`
+	BoolValue   *bool            `json:"boolValue,omitempty"`
+	IntValue    *string          `json:"intValue,omitempty"`
+	DoubleValue *float64         `json:"doubleValue,omitempty"`
+	ArrayValue  *otlpArrayValue  `json:"arrayValue,omitempty"`
+	KvlistValue *otlpKvlistValue `json:"kvlistValue,omitempty"`
+}
+
+type otlpArrayValue struct {
+	Values []otlpAnyValue `json:"values"`
+}
+
+type otlpKvlistValue struct {
+	Values []otlpKeyValue `json:"values"`
+}
+
+// otlpSeverityInfo is SEVERITY_NUMBER_INFO
+const otlpSeverityInfo = 9
+
+func (w *otlpLogWriter) Write(stats []stat) error {
+	observed := strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	records := make([]otlpLogRecord, 0, len(stats))
+	for _, s := range stats {
+		body := []otlpKeyValue{}
+		for _, f := range statFields(s) {
+			body = append(body, otlpKeyValue{Key: f.name, Value: otlpAnyValueOf(f.value)})
+		}
+
+		records = append(records, otlpLogRecord{
+			TimeUnixNano:         strconv.FormatInt(s.getIntervalEnd().UnixNano(), 10),
+			ObservedTimeUnixNano: observed,
+			SeverityNumber:       otlpSeverityInfo,
+			SeverityText:         "INFO",
+			Body:                 otlpAnyValue{KvlistValue: &otlpKvlistValue{Values: body}},
+			Attributes: []otlpKeyValue{
+				{Key: "type", Value: otlpAnyValueOf(statTypeName(s))},
+			},
+		})
+	}
+
+	data := otlpLogsData{
+		ResourceLogs: []otlpResourceLogs{
+			{
+				Resource: otlpResource{Attributes: w.resourceAttributes},
+				ScopeLogs: []otlpScopeLogs{
+					{
+						Scope:      otlpScope{Name: otlpLogScopeName},
+						LogRecords: records,
+					},
+				},
+			},
+		},
+	}
+
+	if err := w.send(data); err != nil {
+		return fmt.Errorf("failed to send otlp logs: %w", err)
+	}
+
+	return nil
+}
+
+func (w *otlpLogWriter) send(data otlpLogsData) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// otlpAnyValueOf converts the field value of stats to AnyValue
+func otlpAnyValueOf(value interface{}) otlpAnyValue {
+	switch v := value.(type) {
+	case string:
+		return otlpAnyValue{StringValue: &v}
+	case []byte:
+		s := string(v)
+		return otlpAnyValue{StringValue: &s}
+	case bool:
+		return otlpAnyValue{BoolValue: &v}
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		return otlpAnyValue{IntValue: &s}
+	case float64:
+		return otlpAnyValue{DoubleValue: &v}
+	case time.Time:
+		s := v.Format(time.RFC3339Nano)
+		return otlpAnyValue{StringValue: &s}
+	case time.Duration:
+		s := strconv.FormatInt(int64(v), 10)
+		return otlpAnyValue{IntValue: &s}
+	}
+
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Slice:
+		values := make([]otlpAnyValue, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			values = append(values, otlpAnyValueOf(rv.Index(i).Interface()))
+		}
+		return otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+
+	case reflect.Struct:
+		values := make([]otlpKeyValue, 0, rv.NumField())
+		for i := 0; i < rv.NumField(); i++ {
+			if rv.Type().Field(i).PkgPath != "" {
+				continue
+			}
+			values = append(values, otlpKeyValue{
+				Key:   rv.Type().Field(i).Name,
+				Value: otlpAnyValueOf(rv.Field(i).Interface()),
+			})
+		}
+		return otlpAnyValue{KvlistValue: &otlpKvlistValue{Values: values}}
+	}
+
+	s := fmt.Sprint(value)
+	return otlpAnyValue{StringValue: &s}
+}
@@ -0,0 +1,102 @@
+package stats
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestOTLPLogWriter(t *testing.T) {
+	var (
+		req  *http.Request
+		body []byte
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		req = r
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	w := NewOTLPLogWriter(
+		server.URL+"/v1/logs",
+		map[string]string{"X-Test-Token": "secret"},
+		server.Client(),
+		map[string]string{"service.name": "test"},
+	)
+
+	intervalEnd := time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC)
+	err := w.Write([]stat{
+		&QueryStat{IntervalEnd: intervalEnd, Text: "SELECT 1", TextFingerprint: 1, ExecutionCount: 2, AvgLatencySeconds: 0.5},
+		&TransactionStat{IntervalEnd: intervalEnd, Fprint: 2, ReadColumns: []string{"Singers.Name"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if req.URL.Path != "/v1/logs" || req.Header.Get("Content-Type") != "application/json" || req.Header.Get("X-Test-Token") != "secret" {
+		t.Errorf("unexpected request: %s %v", req.URL.Path, req.Header)
+	}
+
+	var data otlpLogsData
+	if err := json.Unmarshal(body, &data); err != nil {
+		t.Fatalf("%s: %s", err, body)
+	}
+	if len(data.ResourceLogs) != 1 || len(data.ResourceLogs[0].ScopeLogs) != 1 {
+		t.Fatalf("unexpected payload: %s", body)
+	}
+	resource := data.ResourceLogs[0]
+	if attrs := resource.Resource.Attributes; len(attrs) != 1 || attrs[0].Key != "service.name" || *attrs[0].Value.StringValue != "test" {
+		t.Errorf("unexpected resource attributes: %s", body)
+	}
+	scope := resource.ScopeLogs[0]
+	if scope.Scope.Name != otlpLogScopeName || len(scope.LogRecords) != 2 {
+		t.Fatalf("unexpected scope logs: %s", body)
+	}
+
+	record := scope.LogRecords[0]
+	if record.TimeUnixNano != strconv.FormatInt(intervalEnd.UnixNano(), 10) || record.SeverityNumber != otlpSeverityInfo {
+		t.Errorf("unexpected record: %+v", record)
+	}
+	if attrs := record.Attributes; len(attrs) != 1 || attrs[0].Key != "type" || *attrs[0].Value.StringValue != "QueryStat" {
+		t.Errorf("unexpected attributes: %+v", attrs)
+	}
+
+	fields := map[string]otlpAnyValue{}
+	for _, kv := range record.Body.KvlistValue.Values {
+		fields[kv.Key] = kv.Value
+	}
+	if v := fields["Text"]; v.StringValue == nil || *v.StringValue != "SELECT 1" {
+		t.Errorf("unexpected Text: %+v", v)
+	}
+	// int64 is a string in JSON of OTLP
+	if v := fields["ExecutionCount"]; v.IntValue == nil || *v.IntValue != "2" {
+		t.Errorf("unexpected ExecutionCount: %+v", v)
+	}
+	if v := fields["AvgLatencySeconds"]; v.DoubleValue == nil || *v.DoubleValue != 0.5 {
+		t.Errorf("unexpected AvgLatencySeconds: %+v", v)
+	}
+
+	fields = map[string]otlpAnyValue{}
+	for _, kv := range scope.LogRecords[1].Body.KvlistValue.Values {
+		fields[kv.Key] = kv.Value
+	}
+	if v := fields["ReadColumns"]; v.ArrayValue == nil || len(v.ArrayValue.Values) != 1 || *v.ArrayValue.Values[0].StringValue != "Singers.Name" {
+		t.Errorf("unexpected ReadColumns: %+v", v)
+	}
+}
+
+func TestOTLPLogWriterError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	w := NewOTLPLogWriter(server.URL+"/v1/logs", nil, server.Client(), nil)
+	if err := w.Write(spoolTestStats(1)); err == nil {
+		t.Error("no error for the failed request")
+	}
+}
@@ -0,0 +1,396 @@
+package stats
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/source"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+const parquetJournalExt = ".journal"
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// ParquetWriterConfig is the configuration of ParquetWriter
+type ParquetWriterConfig struct {
+	// Dir to write files into
+	Dir string
+	// MaxRows rolls the file when it has the rows. 0 disables.
+	MaxRows int64
+	// MaxAge rolls the file when it has been opened longer than it. 0 disables.
+	MaxAge time.Duration
+}
+
+// ParquetWriter writes stats into Parquet files partitioned like "<dir>/<family>/date=2006-01-02/part-<opened at>.parquet"
+// by IntervalEnd in UTC, so the directory can be uploaded to the object store as is.
+// A file is kept open for each partition and rolled by MaxRows and MaxAge, because many small files are slow to query.
+// The rows of the open file are also appended to the hidden journal and synced before Write returns,
+// and the files left open by a crash are completed from the journals by NewParquetWriter.
+// The schema is derived from the stat struct. IntervalEnd is TIMESTAMP_MICROS, Elapsed is nanoseconds,
+// and the array fields like ReadColumns and SampleLockRequests are JSON encoded strings.
+type ParquetWriter struct {
+	cfg ParquetWriterConfig
+
+	mu    sync.Mutex
+	files map[parquetPartition]*parquetFile
+}
+
+type parquetPartition struct {
+	family string
+	date   string
+}
+
+// parquetFile is the file being written. It is hidden until completed, because Parquet has the footer at the end.
+type parquetFile struct {
+	path     string
+	tmp      string
+	fw       source.ParquetFile
+	pw       *writer.CSVWriter
+	journal  *os.File
+	rows     int64
+	openedAt time.Time
+}
+
+// NewParquetWriter return new ParquetWriter. Close it to complete the open files.
+// The files left open by the previous process are completed from the journals.
+func NewParquetWriter(cfg ParquetWriterConfig) (*ParquetWriter, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := recoverParquetFiles(cfg.Dir); err != nil {
+		return nil, fmt.Errorf("failed to recover parquet files: %w", err)
+	}
+
+	return &ParquetWriter{
+		cfg:   cfg,
+		files: map[parquetPartition]*parquetFile{},
+	}, nil
+}
+
+// Write stats collection into the open file of each partition.
+// It returns after the stats are synced to the journals, so they survive a crash before the file is completed.
+func (w *ParquetWriter) Write(stats []stat) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, s := range stats {
+		p := parquetPartition{
+			family: statFamilyName(s),
+			date:   s.getIntervalEnd().UTC().Format("2006-01-02"),
+		}
+		if err := w.write(p, s); err != nil {
+			return fmt.Errorf("failed to write parquet file of %s: %w", p.family, err)
+		}
+	}
+
+	for p, f := range w.files {
+		if err := f.journal.Sync(); err != nil {
+			return fmt.Errorf("failed to sync parquet journal of %s: %w", p.family, err)
+		}
+	}
+
+	// the partitions which are not written anymore, like yesterday, are completed too
+	for p, f := range w.files {
+		if w.cfg.MaxAge > 0 && time.Since(f.openedAt) >= w.cfg.MaxAge {
+			delete(w.files, p)
+			if err := f.close(); err != nil {
+				return fmt.Errorf("failed to complete parquet file of %s: %w", p.family, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Close completes the open files
+func (w *ParquetWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var err error
+	for p, f := range w.files {
+		delete(w.files, p)
+		if closeErr := f.close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("failed to complete parquet file of %s: %w", p.family, closeErr)
+		}
+	}
+
+	return err
+}
+
+func (w *ParquetWriter) write(p parquetPartition, s stat) error {
+	row, err := parquetRow(s)
+	if err != nil {
+		return err
+	}
+
+	f, ok := w.files[p]
+	if !ok {
+		f, err = openParquetFile(filepath.Join(w.cfg.Dir, p.family, "date="+p.date), s)
+		if err != nil {
+			return err
+		}
+		w.files[p] = f
+	}
+
+	err = f.pw.Write(row)
+	if err == nil {
+		err = f.appendJournal(s)
+	}
+	if err != nil {
+		// the rows written before are kept
+		delete(w.files, p)
+		if closeErr := f.close(); closeErr != nil {
+			return fmt.Errorf("%w: %v", err, closeErr)
+		}
+		return err
+	}
+	f.rows++
+
+	if w.cfg.MaxRows > 0 && f.rows >= w.cfg.MaxRows {
+		delete(w.files, p)
+		return f.close()
+	}
+
+	return nil
+}
+
+func openParquetFile(dir string, s stat) (*parquetFile, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	name := fmt.Sprintf("part-%s.parquet", now.Format("20060102T150405.000000000Z"))
+	// hidden while writing, the sync tools and query engines ignore it
+	tmp := filepath.Join(dir, "."+name)
+
+	journal, err := os.OpenFile(tmp+parquetJournalExt, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	fw, err := local.NewLocalFileWriter(tmp)
+	if err != nil {
+		journal.Close()
+		_ = os.Remove(journal.Name())
+		return nil, err
+	}
+
+	pw, err := writer.NewCSVWriter(parquetSchema(reflect.Indirect(reflect.ValueOf(s)).Type()), fw, 1)
+	if err != nil {
+		fw.Close()
+		journal.Close()
+		_ = os.Remove(tmp)
+		_ = os.Remove(journal.Name())
+		return nil, err
+	}
+	pw.CompressionType = parquet.CompressionCodec_SNAPPY
+
+	return &parquetFile{
+		path:     filepath.Join(dir, name),
+		tmp:      tmp,
+		fw:       fw,
+		pw:       pw,
+		journal:  journal,
+		openedAt: now,
+	}, nil
+}
+
+// appendJournal appends the stat as a line of {"type":"QueryStat","data":{...}}
+func (f *parquetFile) appendJournal(s stat) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(spoolEnvelope{Type: statTypeName(s), Data: data})
+	if err != nil {
+		return err
+	}
+
+	_, err = f.journal.Write(append(line, '\n'))
+	return err
+}
+
+// close writes the footer and makes the file visible, then the journal is removed
+func (f *parquetFile) close() error {
+	defer f.journal.Close()
+
+	if err := f.pw.WriteStop(); err != nil {
+		f.fw.Close()
+		_ = os.Remove(f.tmp)
+		return err
+	}
+	if err := f.fw.Close(); err != nil {
+		_ = os.Remove(f.tmp)
+		return err
+	}
+	if err := os.Rename(f.tmp, f.path); err != nil {
+		return err
+	}
+
+	return os.Remove(f.journal.Name())
+}
+
+// recoverParquetFiles completes the files left open from the journals under dir
+func recoverParquetFiles(dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name := info.Name()
+		if info.IsDir() || !strings.HasPrefix(name, ".part-") || !strings.HasSuffix(name, parquetJournalExt) {
+			return nil
+		}
+
+		return recoverParquetFile(path)
+	})
+}
+
+// recoverParquetFile writes the rows in the journal into the new file, then removes the journal and the left file.
+// The new file has its own journal, so a crash while recovering doesn't lose the rows.
+func recoverParquetFile(journalPath string) error {
+	stats, err := readParquetJournal(journalPath)
+	if err != nil {
+		return err
+	}
+
+	if len(stats) > 0 {
+		f, err := openParquetFile(filepath.Dir(journalPath), stats[0])
+		if err != nil {
+			return err
+		}
+		for _, s := range stats {
+			row, err := parquetRow(s)
+			if err == nil {
+				err = f.pw.Write(row)
+			}
+			if err == nil {
+				err = f.appendJournal(s)
+			}
+			if err != nil {
+				// the journal of the previous process is recovered again next time
+				f.fw.Close()
+				f.journal.Close()
+				_ = os.Remove(f.tmp)
+				_ = os.Remove(f.journal.Name())
+				return fmt.Errorf("failed to recover %s: %w", journalPath, err)
+			}
+		}
+		if err := f.close(); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Remove(strings.TrimSuffix(journalPath, parquetJournalExt)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return os.Remove(journalPath)
+}
+
+// readParquetJournal returns the stats in the journal. The broken lines, like the last line written partially, are skipped.
+func readParquetJournal(path string) ([]stat, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var stats []stat
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var e spoolEnvelope
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		s, ok := newStatOfType(e.Type)
+		if !ok {
+			continue
+		}
+		if err := json.Unmarshal(e.Data, s); err != nil {
+			continue
+		}
+		stats = append(stats, s)
+	}
+
+	return stats, scanner.Err()
+}
+
+// parquetSchema returns the schema of parquet-go CSVWriter from the exported fields of the stat struct
+func parquetSchema(t reflect.Type) []string {
+	var md []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		md = append(md, fmt.Sprintf("name=%s, %s, repetitiontype=REQUIRED", toSnakeCase(f.Name), parquetType(f.Type)))
+	}
+
+	return md
+}
+
+func parquetType(t reflect.Type) string {
+	switch t {
+	case timeType:
+		return "type=INT64, convertedtype=TIMESTAMP_MICROS"
+	case durationType:
+		return "type=INT64"
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "type=BOOLEAN"
+	case reflect.Int64:
+		return "type=INT64"
+	case reflect.Float64:
+		return "type=DOUBLE"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "type=BYTE_ARRAY"
+		}
+	}
+
+	return "type=BYTE_ARRAY, convertedtype=UTF8"
+}
+
+// parquetRow converts the field values to the types of parquetSchema
+func parquetRow(s stat) ([]interface{}, error) {
+	fields := statFields(s)
+	row := make([]interface{}, 0, len(fields))
+	for _, f := range fields {
+		switch v := f.value.(type) {
+		case time.Time:
+			row = append(row, v.UnixNano()/int64(time.Microsecond))
+		case time.Duration:
+			row = append(row, int64(v))
+		case bool, int64, float64, string:
+			row = append(row, v)
+		case []byte:
+			row = append(row, string(v))
+		default:
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode %s: %w", f.name, err)
+			}
+			row = append(row, string(b))
+		}
+	}
+
+	return row, nil
+}
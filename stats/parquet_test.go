@@ -0,0 +1,147 @@
+package stats
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+// parquetFiles returns the number of rows of each completed file in the partition
+func parquetFiles(t *testing.T, dir string) []int64 {
+	t.Helper()
+
+	paths, err := filepath.Glob(filepath.Join(dir, "part-*.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := make([]int64, 0, len(paths))
+	for _, path := range paths {
+		fr, err := local.NewLocalFileReader(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pr, err := reader.NewParquetReader(fr, nil, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, pr.GetNumRows())
+		pr.ReadStop()
+		fr.Close()
+	}
+
+	return rows
+}
+
+func TestParquetWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w, err := NewParquetWriter(ParquetWriterConfig{Dir: dir, MaxRows: 3, MaxAge: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	intervalEnd := time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC)
+	partition := filepath.Join(dir, "query", "date=2021-11-01")
+
+	// the collections are appended to the open file
+	for i := 0; i < 2; i++ {
+		err := w.Write([]stat{
+			&QueryStat{IntervalEnd: intervalEnd.Add(time.Duration(i) * time.Minute), Text: "SELECT 1", TextFingerprint: 1},
+			&QueryStat{IntervalEnd: intervalEnd.Add(time.Duration(i) * time.Minute), Text: "SELECT 2", TextFingerprint: 2},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// rolled by MaxRows
+	if rows := parquetFiles(t, partition); len(rows) != 1 || rows[0] != 3 {
+		t.Errorf("unexpected files before closing: %v", rows)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if rows := parquetFiles(t, partition); len(rows) != 2 || rows[0]+rows[1] != 4 {
+		t.Errorf("unexpected files after closing: %v", rows)
+	}
+
+	hidden, err := filepath.Glob(filepath.Join(partition, ".part-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hidden) != 0 {
+		t.Errorf("incomplete files are left: %v", hidden)
+	}
+}
+
+func TestParquetWriterRecover(t *testing.T) {
+	dir, err := ioutil.TempDir("", "parquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w, err := NewParquetWriter(ParquetWriterConfig{Dir: dir, MaxAge: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	intervalEnd := time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC)
+	partition := filepath.Join(dir, "query", "date=2021-11-01")
+
+	for i := 0; i < 2; i++ {
+		err := w.Write([]stat{
+			&QueryStat{IntervalEnd: intervalEnd.Add(time.Duration(i) * time.Minute), Text: "SELECT 1", TextFingerprint: 1},
+			&QueryStat{IntervalEnd: intervalEnd.Add(time.Duration(i) * time.Minute), Text: "SELECT 2", TextFingerprint: 2},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if rows := parquetFiles(t, partition); len(rows) != 0 {
+		t.Fatalf("unexpected files before crash: %v", rows)
+	}
+
+	// crashed while appending the journal
+	journals, err := filepath.Glob(filepath.Join(partition, ".part-*.journal"))
+	if err != nil || len(journals) != 1 {
+		t.Fatalf("unexpected journals: %v, %v", journals, err)
+	}
+	journal, err := os.OpenFile(journals[0], os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := journal.WriteString(`{"type":"QueryStat","da`); err != nil {
+		t.Fatal(err)
+	}
+	journal.Close()
+
+	// crashed without Close, then the next process completes the file from the journal
+	if _, err := NewParquetWriter(ParquetWriterConfig{Dir: dir, MaxAge: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+
+	if rows := parquetFiles(t, partition); len(rows) != 1 || rows[0] != 4 {
+		t.Errorf("unexpected files after recovering: %v", rows)
+	}
+
+	hidden, err := filepath.Glob(filepath.Join(partition, ".part-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hidden) != 0 {
+		t.Errorf("incomplete files are left: %v", hidden)
+	}
+}
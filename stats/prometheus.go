@@ -0,0 +1,201 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const prometheusMetricPrefix = "spanner_stats_"
+
+// PrometheusWriter keeps the latest stats as gauges, and exposes them as Prometheus text format.
+// Labels are bounded to fingerprints and short texts, and series which are not written for TTL are expired,
+// so the fingerprints left from the top-N disappear.
+type PrometheusWriter struct {
+	ttl        time.Duration
+	textLength int
+
+	mu     sync.Mutex
+	series map[string]*prometheusSeries
+}
+
+type prometheusSeries struct {
+	name      string
+	labels    []prometheusLabel
+	value     float64
+	updatedAt time.Time
+}
+
+type prometheusLabel struct {
+	name  string
+	value string
+}
+
+var prometheusHelps = map[string]string{
+	"query_execution_count":                        "Number of times Cloud Spanner saw the query during the interval.",
+	"query_avg_latency_seconds":                    "Average length of time, in seconds, for each query execution.",
+	"query_avg_rows":                               "Average number of rows that the query returned.",
+	"query_avg_bytes":                              "Average number of data bytes that the query returned.",
+	"query_avg_rows_scanned":                       "Average number of rows that the query scanned.",
+	"query_avg_cpu_seconds":                        "Average number of seconds of CPU time Cloud Spanner spent on all operations to execute the query.",
+	"transaction_commit_attempt_count":             "Total number of commit attempts on the transaction.",
+	"transaction_commit_failed_precondition_count": "Total number of precondition failures for the transaction.",
+	"transaction_commit_abort_count":               "Number of times the commits were aborted for the transaction.",
+	"transaction_avg_participants":                 "Average number of participants in each commit attempt.",
+	"transaction_avg_total_latency_seconds":        "Average seconds taken from the first operation of the transaction to commit/abort.",
+	"transaction_avg_commit_latency_seconds":       "Average seconds taken to perform the commit operation.",
+	"transaction_avg_bytes":                        "Average number of bytes written by the transaction.",
+	"lock_wait_seconds":                            "Cumulative lock wait time of lock conflicts recorded for all the columns in the row range.",
+	"collector_errors":                             "Number of failed collections of the stat family since the collector started.",
+}
+
+// NewPrometheusWriter return new PrometheusWriter. It is also the http.Handler of /metrics.
+func NewPrometheusWriter(ttl time.Duration, textLength int) *PrometheusWriter {
+	return &PrometheusWriter{
+		ttl:        ttl,
+		textLength: textLength,
+		series:     map[string]*prometheusSeries{},
+	}
+}
+
+// Write stats collection as gauges
+func (w *PrometheusWriter) Write(stats []stat) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+
+	for _, s := range stats {
+		switch s := s.(type) {
+		case *QueryStat:
+			labels := []prometheusLabel{
+				{name: "fingerprint", value: strconv.FormatInt(s.TextFingerprint, 10)},
+				{name: "text", value: w.shortText(s.Text)},
+			}
+			w.set(now, "query_execution_count", labels, float64(s.ExecutionCount))
+			w.set(now, "query_avg_latency_seconds", labels, s.AvgLatencySeconds)
+			w.set(now, "query_avg_rows", labels, s.AvgRows)
+			w.set(now, "query_avg_bytes", labels, s.AvgBytes)
+			w.set(now, "query_avg_rows_scanned", labels, s.AvgRowsScanned)
+			w.set(now, "query_avg_cpu_seconds", labels, s.AvgCPUSeconds)
+
+		case *TransactionStat:
+			labels := []prometheusLabel{
+				{name: "fprint", value: strconv.FormatInt(s.Fprint, 10)},
+			}
+			w.set(now, "transaction_commit_attempt_count", labels, float64(s.CommitAttemptCount))
+			w.set(now, "transaction_commit_failed_precondition_count", labels, float64(s.CommitFailedPreconditionCount))
+			w.set(now, "transaction_commit_abort_count", labels, float64(s.CommitAbortCount))
+			w.set(now, "transaction_avg_participants", labels, s.AvgParticipants)
+			w.set(now, "transaction_avg_total_latency_seconds", labels, s.AvgTotalLatencySeconds)
+			w.set(now, "transaction_avg_commit_latency_seconds", labels, s.AvgCommitLatencySeconds)
+			w.set(now, "transaction_avg_bytes", labels, s.AvgBytes)
+
+		case *LockStat:
+			labels := []prometheusLabel{
+				{name: "row_range_start_key", value: w.shortText(string(s.RowRangeStartKey))},
+			}
+			w.set(now, "lock_wait_seconds", labels, s.LockWaitSeconds)
+
+		case *CollectorErrorStat:
+			labels := []prometheusLabel{
+				{name: "family", value: s.Family},
+			}
+			w.set(now, "collector_errors", labels, float64(s.ErrorCount))
+		}
+	}
+
+	return nil
+}
+
+func (w *PrometheusWriter) set(now time.Time, name string, labels []prometheusLabel, value float64) {
+	key := name
+	for _, l := range labels {
+		key += "\xff" + l.name + "\xff" + l.value
+	}
+
+	w.series[key] = &prometheusSeries{
+		name:      name,
+		labels:    labels,
+		value:     value,
+		updatedAt: now,
+	}
+}
+
+// shortText collapses whitespaces and truncates the text, for keeping label values small.
+func (w *PrometheusWriter) shortText(text string) string {
+	text = strings.Join(strings.Fields(strings.ToValidUTF8(text, "?")), " ")
+	if w.textLength <= 0 || utf8.RuneCountInString(text) <= w.textLength {
+		return text
+	}
+
+	return string([]rune(text)[:w.textLength])
+}
+
+// ServeHTTP exposes the gauges as Prometheus text format
+func (w *PrometheusWriter) ServeHTTP(rw http.ResponseWriter, _ *http.Request) {
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = w.writeTo(rw)
+}
+
+func (w *PrometheusWriter) writeTo(out io.Writer) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	byName := map[string][]*prometheusSeries{}
+	for key, s := range w.series {
+		if w.ttl > 0 && now.Sub(s.updatedAt) > w.ttl {
+			delete(w.series, key)
+			continue
+		}
+		byName[s.name] = append(byName[s.name], s)
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	b := &strings.Builder{}
+	for _, name := range names {
+		series := byName[name]
+		sort.Slice(series, func(i, j int) bool {
+			return prometheusLabelString(series[i].labels) < prometheusLabelString(series[j].labels)
+		})
+
+		fmt.Fprintf(b, "# HELP %s%s %s\n", prometheusMetricPrefix, name, prometheusHelps[name])
+		fmt.Fprintf(b, "# TYPE %s%s gauge\n", prometheusMetricPrefix, name)
+		for _, s := range series {
+			fmt.Fprintf(
+				b,
+				"%s%s{%s} %s\n",
+				prometheusMetricPrefix,
+				name,
+				prometheusLabelString(s.labels),
+				strconv.FormatFloat(s.value, 'g', -1, 64),
+			)
+		}
+	}
+
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+var prometheusLabelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func prometheusLabelString(labels []prometheusLabel) string {
+	result := make([]string, 0, len(labels))
+	for _, l := range labels {
+		result = append(result, l.name+`="`+prometheusLabelValueReplacer.Replace(l.value)+`"`)
+	}
+
+	return strings.Join(result, ",")
+}
@@ -0,0 +1,55 @@
+package stats
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusWriter(t *testing.T) {
+	w := NewPrometheusWriter(time.Hour, 8)
+
+	intervalEnd := time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC)
+	err := w.Write([]stat{
+		// the same prefix, distinguished by the fingerprint
+		&QueryStat{IntervalEnd: intervalEnd, Text: "SELECT * FROM Singers WHERE SingerId = 1", TextFingerprint: 1, ExecutionCount: 10},
+		&QueryStat{IntervalEnd: intervalEnd, Text: "SELECT * FROM Singers WHERE FirstName = 'a'", TextFingerprint: 2, ExecutionCount: 20},
+		&CollectorErrorStat{CollectedAt: intervalEnd, Family: "query/minute", ErrorCount: 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &strings.Builder{}
+	if err := w.writeTo(b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+
+	for _, want := range []string{
+		`spanner_stats_query_execution_count{fingerprint="1",text="SELECT *"} 10`,
+		`spanner_stats_query_execution_count{fingerprint="2",text="SELECT *"} 20`,
+		`spanner_stats_collector_errors{family="query/minute"} 3`,
+	} {
+		if !strings.Contains(out, want+"\n") {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestPrometheusWriterTTL(t *testing.T) {
+	w := NewPrometheusWriter(time.Millisecond, 64)
+
+	if err := w.Write([]stat{&QueryStat{Text: "SELECT 1", TextFingerprint: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	b := &strings.Builder{}
+	if err := w.writeTo(b); err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() != 0 {
+		t.Errorf("expired series are exposed:\n%s", b.String())
+	}
+}
@@ -0,0 +1,250 @@
+package stats
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// redactPlaceholder replaces the redacted literals and masks
+const redactPlaceholder = "?"
+
+// redactTextFields are the fields of the query text and the lock key, which contain the literal values
+var redactTextFields = []string{"Text", "RowRangeStartKey"}
+
+type redactWriter struct {
+	writer   Writer
+	literals bool
+	masks    []*regexp.Regexp
+}
+
+// RedactOption configures the redaction of NewRedactWriter
+type RedactOption func(*redactWriter)
+
+// WithRedactLiterals replaces string, number and bytes literals of the query text with "?", see RedactSQL
+func WithRedactLiterals() RedactOption {
+	return func(w *redactWriter) {
+		w.literals = true
+	}
+}
+
+// WithRedactMasks replaces the matches of the regexps in the query text with "?", after the literals
+func WithRedactMasks(masks ...*regexp.Regexp) RedactOption {
+	return func(w *redactWriter) {
+		w.masks = append(w.masks, masks...)
+	}
+}
+
+// NewRedactWriter return new Writer which redacts the query text and the key of LockStat before writing to the writer.
+// Wrap the top level Writer, so every sink gets the redacted text. The original stats are not modified.
+func NewRedactWriter(writer Writer, opts ...RedactOption) Writer {
+	w := &redactWriter{
+		writer: writer,
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
+}
+
+func (w *redactWriter) Write(stats []stat) error {
+	redacted := make([]stat, 0, len(stats))
+	for _, s := range stats {
+		redacted = append(redacted, w.redact(s))
+	}
+
+	return w.writer.Write(redacted)
+}
+
+// redact returns the copy of the stat with the redacted text
+func (w *redactWriter) redact(s stat) stat {
+	v := reflect.Indirect(reflect.ValueOf(s))
+	var c reflect.Value
+
+	for _, name := range redactTextFields {
+		var text string
+		switch value := statFieldValue(s, name).(type) {
+		case string:
+			text = value
+		case []byte:
+			// the readable key like Singers(1,"Alice")
+			text = string(value)
+		default:
+			continue
+		}
+
+		if w.literals {
+			text = RedactSQL(text)
+		}
+		for _, re := range w.masks {
+			text = re.ReplaceAllLiteralString(text, redactPlaceholder)
+		}
+
+		if !c.IsValid() {
+			c = reflect.New(v.Type())
+			c.Elem().Set(v)
+		}
+		if f := c.Elem().FieldByName(name); f.Kind() == reflect.Slice {
+			f.SetBytes([]byte(text))
+		} else {
+			f.SetString(text)
+		}
+	}
+
+	if !c.IsValid() {
+		return s
+	}
+
+	return c.Interface().(stat)
+}
+
+// RedactSQL replaces string, number and bytes literals in the GoogleSQL text with "?".
+// Identifiers, quoted identifiers, query parameters and comments are kept.
+// Unterminated literal like in the truncated text is redacted to the end.
+func RedactSQL(text string) string {
+	b := strings.Builder{}
+	b.Grow(len(text))
+
+	for i := 0; i < len(text); {
+		c := text[i]
+
+		switch {
+		// comments
+		case c == '#' || (c == '-' && strings.HasPrefix(text[i:], "--")):
+			end := strings.IndexByte(text[i:], '\n')
+			if end < 0 {
+				end = len(text) - i
+			}
+			b.WriteString(text[i : i+end])
+			i += end
+
+		case c == '/' && strings.HasPrefix(text[i:], "/*"):
+			end := strings.Index(text[i+2:], "*/")
+			if end < 0 {
+				end = len(text) - i
+			} else {
+				end += 4
+			}
+			b.WriteString(text[i : i+end])
+			i += end
+
+		// quoted identifiers
+		case c == '`':
+			end := sqlQuotedEnd(text, i)
+			b.WriteString(text[i:end])
+			i = end
+
+		case c == '\'' || c == '"':
+			i = sqlQuotedEnd(text, i)
+			b.WriteString(redactPlaceholder)
+
+		// query parameters like @from and system variables like @@optimizer_version
+		case c == '@':
+			end := i + 1
+			for end < len(text) && (text[end] == '@' || isSQLIdentChar(text[end])) {
+				end++
+			}
+			b.WriteString(text[i:end])
+			i = end
+
+		case isSQLIdentStart(c):
+			end := i + 1
+			for end < len(text) && isSQLIdentChar(text[end]) {
+				end++
+			}
+
+			// prefixed literals like b'', r'', rb'' and br''
+			prefix := strings.ToLower(text[i:end])
+			if end < len(text) && (text[end] == '\'' || text[end] == '"') &&
+				(prefix == "b" || prefix == "r" || prefix == "rb" || prefix == "br") {
+				i = sqlQuotedEnd(text, end)
+				b.WriteString(redactPlaceholder)
+				continue
+			}
+
+			b.WriteString(text[i:end])
+			i = end
+
+		case isSQLDigit(c) || (c == '.' && i+1 < len(text) && isSQLDigit(text[i+1])):
+			i = sqlNumberEnd(text, i)
+			b.WriteString(redactPlaceholder)
+
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+
+	return b.String()
+}
+
+// sqlQuotedEnd returns the index after the closing quote of the quoted text starting at i.
+// The quote after a backslash doesn't close even the raw literal, it keeps both characters.
+func sqlQuotedEnd(text string, i int) int {
+	quote := text[i : i+1]
+	if q3 := strings.Repeat(quote, 3); quote != "`" && strings.HasPrefix(text[i:], q3) {
+		quote = q3
+	}
+
+	for j := i + len(quote); j < len(text); j++ {
+		if text[j] == '\\' {
+			j++
+			continue
+		}
+		if strings.HasPrefix(text[j:], quote) {
+			return j + len(quote)
+		}
+	}
+
+	return len(text)
+}
+
+// sqlNumberEnd returns the index after the number literal like 1, 0x1F, 1.5 and 1e-3 starting at i
+func sqlNumberEnd(text string, i int) int {
+	if strings.HasPrefix(strings.ToLower(text[i:]), "0x") {
+		j := i + 2
+		for j < len(text) && strings.IndexByte("0123456789abcdefABCDEF", text[j]) >= 0 {
+			j++
+		}
+		return j
+	}
+
+	j := i
+	for j < len(text) && isSQLDigit(text[j]) {
+		j++
+	}
+	if j < len(text) && text[j] == '.' {
+		j++
+		for j < len(text) && isSQLDigit(text[j]) {
+			j++
+		}
+	}
+	if j < len(text) && (text[j] == 'e' || text[j] == 'E') {
+		k := j + 1
+		if k < len(text) && (text[k] == '+' || text[k] == '-') {
+			k++
+		}
+		if k < len(text) && isSQLDigit(text[k]) {
+			j = k
+			for j < len(text) && isSQLDigit(text[j]) {
+				j++
+			}
+		}
+	}
+
+	return j
+}
+
+func isSQLDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isSQLIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isSQLIdentChar(c byte) bool {
+	return isSQLIdentStart(c) || isSQLDigit(c)
+}
@@ -0,0 +1,162 @@
+package stats
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestRedactSQL(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "single quoted",
+			text: "SELECT * FROM Singers WHERE FirstName = 'Marc'",
+			want: "SELECT * FROM Singers WHERE FirstName = ?",
+		},
+		{
+			name: "double quoted",
+			text: `SELECT * FROM Singers WHERE FirstName = "Marc"`,
+			want: "SELECT * FROM Singers WHERE FirstName = ?",
+		},
+		{
+			name: "escaped quote",
+			text: `SELECT 'it\'s', 'a\\' FROM t`,
+			want: "SELECT ?, ? FROM t",
+		},
+		{
+			name: "triple quoted",
+			text: "SELECT '''it's\n'multi' line''', \"\"\"say \"hi\" \"\"\" FROM t",
+			want: "SELECT ?, ? FROM t",
+		},
+		{
+			name: "raw",
+			text: `SELECT r'\d+\'', R"\w\\" FROM t`,
+			want: "SELECT ?, ? FROM t",
+		},
+		{
+			name: "bytes",
+			text: `SELECT b'abc', B"\x01", rb'\d', BR"\w" FROM t`,
+			want: "SELECT ?, ?, ?, ? FROM t",
+		},
+		{
+			name: "prefix-like identifiers",
+			text: "SELECT b, r, rb FROM t WHERE br = 1",
+			want: "SELECT b, r, rb FROM t WHERE br = ?",
+		},
+		{
+			name: "integer and float",
+			text: "SELECT 1, 1.5, .5, 2. FROM t LIMIT 10",
+			want: "SELECT ?, ?, ?, ? FROM t LIMIT ?",
+		},
+		{
+			name: "hex",
+			text: "SELECT 0x1F, 0XaB FROM t",
+			want: "SELECT ?, ? FROM t",
+		},
+		{
+			name: "exponent",
+			text: "SELECT 1e10, 1.5E-3, 2e+5 FROM t",
+			want: "SELECT ?, ?, ? FROM t",
+		},
+		{
+			name: "digits in identifiers",
+			text: "SELECT col1 FROM t2 WHERE t2.col1 > 0",
+			want: "SELECT col1 FROM t2 WHERE t2.col1 > ?",
+		},
+		{
+			name: "line comments",
+			text: "SELECT 1 -- id = 'a'\n# 2\nFROM t",
+			want: "SELECT ? -- id = 'a'\n# 2\nFROM t",
+		},
+		{
+			name: "block comment",
+			text: "SELECT /* 'a', 1 */ 2 FROM t",
+			want: "SELECT /* 'a', 1 */ ? FROM t",
+		},
+		{
+			name: "unterminated block comment",
+			text: "SELECT 1 /* 'a'",
+			want: "SELECT ? /* 'a'",
+		},
+		{
+			name: "backtick identifiers",
+			text: "SELECT `Order 1`, `a'b` FROM `my-table` WHERE x = 'y'",
+			want: "SELECT `Order 1`, `a'b` FROM `my-table` WHERE x = ?",
+		},
+		{
+			name: "query parameters",
+			text: "SELECT * FROM t WHERE id = @id1 AND name = @name AND @@optimizer_version = 3",
+			want: "SELECT * FROM t WHERE id = @id1 AND name = @name AND @@optimizer_version = ?",
+		},
+		{
+			name: "truncated string",
+			text: "SELECT * FROM t WHERE name = 'Mar",
+			want: "SELECT * FROM t WHERE name = ?",
+		},
+		{
+			name: "truncated triple quoted",
+			text: "SELECT * FROM t WHERE name = '''a'b",
+			want: "SELECT * FROM t WHERE name = ?",
+		},
+		{
+			name: "truncated number",
+			text: "SELECT * FROM t LIMIT 1e",
+			want: "SELECT * FROM t LIMIT ?e",
+		},
+		{
+			name: "empty",
+			text: "",
+			want: "",
+		},
+		{
+			name: "non-ASCII",
+			text: "SELECT 名前 FROM t WHERE 名前 = 'ユーザー'",
+			want: "SELECT 名前 FROM t WHERE 名前 = ?",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RedactSQL(tt.text); got != tt.want {
+				t.Errorf("RedactSQL(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactWriter(t *testing.T) {
+	out := &failingWriter{}
+	w := NewRedactWriter(out, WithRedactLiterals(), WithRedactMasks(regexp.MustCompile(`Singers`)))
+
+	original := &QueryStat{Text: "SELECT * FROM Singers WHERE SingerId = 1", TextFingerprint: 1}
+	if err := w.Write([]stat{original, &QueryTotalStat{ExecutionCount: 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := out.written[0].(*QueryStat).Text; got != "SELECT * FROM ? WHERE SingerId = ?" {
+		t.Errorf("unexpected text: %q", got)
+	}
+	if original.Text != "SELECT * FROM Singers WHERE SingerId = 1" {
+		t.Errorf("the original stat is modified: %q", original.Text)
+	}
+}
+
+func TestRedactWriterLockKey(t *testing.T) {
+	out := &failingWriter{}
+	w := NewRedactWriter(out, WithRedactLiterals(), WithRedactMasks(regexp.MustCompile(`Singers`)))
+
+	original := &LockStat{RowRangeStartKey: []byte(`Singers(1,"Alice")`), LockWaitSeconds: 1}
+	if err := w.Write([]stat{original}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := string(out.written[0].(*LockStat).RowRangeStartKey); got != "?(?,?)" {
+		t.Errorf("unexpected key: %q", got)
+	}
+	if string(original.RowRangeStartKey) != `Singers(1,"Alice")` {
+		t.Errorf("the original stat is modified: %q", original.RowRangeStartKey)
+	}
+}
@@ -0,0 +1,274 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	"go.uber.org/zap"
+	"google.golang.org/api/iterator"
+)
+
+type snapshotGetter func(context.Context, time.Time) ([]stat, error)
+
+// OldestActiveQueryStat track the long-running queries at the moment of collecting
+// followed https://cloud.google.com/spanner/docs/introspection/oldest-active-queries
+type OldestActiveQueryStat struct {
+	CollectedAt     time.Time     `spanner:"-"`
+	StartTime       time.Time     `spanner:"START_TIME"`
+	TextFingerprint int64         `spanner:"TEXT_FINGERPRINT"`
+	Text            string        `spanner:"TEXT"`
+	TextTruncated   bool          `spanner:"TEXT_TRUNCATED"`
+	SessionID       string        `spanner:"SESSION_ID"`
+	Elapsed         time.Duration `spanner:"-"`
+}
+
+// snapshot has no interval, so returns collected time instead.
+func (q *OldestActiveQueryStat) getIntervalEnd() time.Time {
+	return q.CollectedAt
+}
+
+// GetOldestActiveQueries returns Stat collection of currently running queries
+func (c *Client) getOldestActiveQueries(ctx context.Context, now time.Time) ([]stat, error) {
+	stmt := spanner.NewStatement(
+		`SELECT
+	start_time,
+	text_fingerprint,
+	text,
+	text_truncated,
+	session_id
+FROM spanner_sys.oldest_active_queries
+ORDER BY start_time ASC;`,
+	)
+
+	iter := c.spannerClient.Single().Query(ctx, stmt)
+	defer iter.Stop()
+
+	var results []stat
+
+	for {
+		row, err := iter.Next()
+		if err != nil {
+			if err == iterator.Done {
+				break
+			}
+			return nil, err
+		}
+
+		var b OldestActiveQueryStat
+		err = row.ToStruct(&b)
+		if err != nil {
+			return nil, err
+		}
+
+		b.Text = strings.TrimSpace(b.Text)
+		b.CollectedAt = now
+		b.Elapsed = now.Sub(b.StartTime)
+		results = append(results, &b)
+	}
+
+	return results, nil
+}
+
+// ActivePartitionedDMLStat track the progress of running partitioned DMLs at the moment of collecting.
+// When a partitioned DML disappears from the table, it is written once more with Completed.
+// followed https://cloud.google.com/spanner/docs/introspection/active-partitioned-dmls
+type ActivePartitionedDMLStat struct {
+	CollectedAt                  time.Time     `spanner:"-"`
+	Text                         string        `spanner:"TEXT"`
+	TextFingerprint              int64         `spanner:"TEXT_FINGERPRINT"`
+	SessionID                    string        `spanner:"SESSION_ID"`
+	NumPartitionsTotal           int64         `spanner:"NUM_PARTITIONS_TOTAL"`
+	NumPartitionsComplete        int64         `spanner:"NUM_PARTITIONS_COMPLETE"`
+	NumTrivialPartitionsComplete int64         `spanner:"NUM_TRIVIAL_PARTITIONS_COMPLETE"`
+	Progress                     float64       `spanner:"PROGRESS"`
+	RowsProcessed                int64         `spanner:"ROWS_PROCESSED"`
+	StartTimestamp               time.Time     `spanner:"START_TIMESTAMP"`
+	LastUpdateTimestamp          time.Time     `spanner:"LAST_UPDATE_TIMESTAMP"`
+	Elapsed                      time.Duration `spanner:"-"`
+	Completed                    bool          `spanner:"-"`
+}
+
+// snapshot has no interval, so returns collected time instead.
+func (q *ActivePartitionedDMLStat) getIntervalEnd() time.Time {
+	return q.CollectedAt
+}
+
+func (q *ActivePartitionedDMLStat) key() string {
+	return fmt.Sprintf("%s/%d/%d", q.SessionID, q.TextFingerprint, q.StartTimestamp.UnixNano())
+}
+
+// GetActivePartitionedDMLs returns Stat collection of currently running partitioned DMLs
+func (c *Client) getActivePartitionedDMLs(ctx context.Context, now time.Time) ([]*ActivePartitionedDMLStat, error) {
+	stmt := spanner.NewStatement(
+		`SELECT
+	text,
+	text_fingerprint,
+	session_id,
+	num_partitions_total,
+	num_partitions_complete,
+	num_trivial_partitions_complete,
+	progress,
+	rows_processed,
+	start_timestamp,
+	last_update_timestamp
+FROM spanner_sys.active_partitioned_dmls
+ORDER BY start_timestamp ASC;`,
+	)
+
+	iter := c.spannerClient.Single().Query(ctx, stmt)
+	defer iter.Stop()
+
+	var results []*ActivePartitionedDMLStat
+
+	for {
+		row, err := iter.Next()
+		if err != nil {
+			if err == iterator.Done {
+				break
+			}
+			return nil, err
+		}
+
+		var b ActivePartitionedDMLStat
+		err = row.ToStruct(&b)
+		if err != nil {
+			return nil, err
+		}
+
+		b.Text = strings.TrimSpace(b.Text)
+		b.CollectedAt = now
+		b.Elapsed = now.Sub(b.StartTimestamp)
+		results = append(results, &b)
+	}
+
+	return results, nil
+}
+
+// SnapshotWorker of live snapshot tables like SPANNER_SYS.OLDEST_ACTIVE_QUERIES.
+// These tables have no interval, so it polls them on its own period.
+type SnapshotWorker struct {
+	client   *Client
+	period   time.Duration
+	writer   Writer
+	ctx      context.Context
+	canceler context.CancelFunc
+	getters  []snapshotGetter
+	logger   *zap.Logger
+
+	// running partitioned DMLs seen at the last tick, for detecting the completion.
+	activeDMLs map[string]*ActivePartitionedDMLStat
+}
+
+// SnapshotWorkerOption configures the SnapshotWorker
+type SnapshotWorkerOption func(*SnapshotWorker)
+
+// WithSnapshotLogger sets the logger for collecting errors
+func WithSnapshotLogger(logger *zap.Logger) SnapshotWorkerOption {
+	return func(w *SnapshotWorker) {
+		w.logger = logger
+	}
+}
+
+// NewSnapshotWorker returns the new snapshot collector
+func NewSnapshotWorker(client *Client, period time.Duration, writer Writer, opts ...SnapshotWorkerOption) *SnapshotWorker {
+	w := &SnapshotWorker{
+		client:     client,
+		period:     period,
+		writer:     writer,
+		logger:     newDefaultLogger(),
+		activeDMLs: map[string]*ActivePartitionedDMLStat{},
+	}
+	w.getters = []snapshotGetter{
+		client.getOldestActiveQueries,
+		w.getPartitionedDMLs,
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
+}
+
+// Start the snapshot collector
+func (w *SnapshotWorker) Start(ctx context.Context) {
+	w.ctx, w.canceler = context.WithCancel(ctx)
+
+	// in the first time, do it as soon as possible.
+	w.ticker(w.ctx)
+
+	timer := time.NewTicker(w.period)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-timer.C:
+			w.ticker(w.ctx)
+		}
+	}
+}
+
+// Stop the snapshot collector
+func (w *SnapshotWorker) Stop() {
+	w.canceler()
+
+	if f, ok := w.writer.(Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *SnapshotWorker) ticker(ctx context.Context) {
+	now := time.Now()
+
+	for _, getter := range w.getters {
+		stats, err := getter(ctx, now)
+		if err != nil {
+			w.logger.Error("failed to collect snapshot", zap.Error(err))
+			continue
+		}
+		if len(stats) == 0 {
+			continue
+		}
+		if err := w.writer.Write(stats); err != nil {
+			w.logger.Error("failed to write snapshot", zap.Error(err))
+		}
+	}
+}
+
+// getPartitionedDMLs returns running partitioned DMLs, and completed ones which disappeared since the last tick.
+// On failure, the seen DMLs are kept, because empty result can not be treated as the completion of them.
+func (w *SnapshotWorker) getPartitionedDMLs(ctx context.Context, now time.Time) ([]stat, error) {
+	dmls, err := w.client.getActivePartitionedDMLs(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []stat
+
+	current := make(map[string]*ActivePartitionedDMLStat, len(dmls))
+	for _, d := range dmls {
+		current[d.key()] = d
+		results = append(results, d)
+	}
+
+	for k, d := range w.activeDMLs {
+		if _, ok := current[k]; ok {
+			continue
+		}
+
+		completed := *d
+		completed.CollectedAt = now
+		completed.Elapsed = now.Sub(d.StartTimestamp)
+		completed.Completed = true
+		results = append(results, &completed)
+	}
+
+	w.activeDMLs = current
+
+	return results, nil
+}
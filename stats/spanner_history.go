@@ -0,0 +1,248 @@
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	"go.uber.org/zap"
+	"google.golang.org/api/option"
+	databasepb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+)
+
+// spannerHistoryMaxRows is the rows in a commit, it keeps a commit under the mutation limit
+const spannerHistoryMaxRows = 500
+
+var spannerCreateTableRegexp = regexp.MustCompile("(?is)^\\s*CREATE\\s+TABLE\\s+`?(\\w+)`?")
+
+// SpannerHistoryWriter inserts stats into the history tables like "QueryStatsHistory",
+// because Spanner keeps the stats only for hours or days.
+type SpannerHistoryWriter struct {
+	client      *spanner.Client
+	tablePrefix string
+	logger      *zap.Logger
+}
+
+// NewSpannerHistoryWriter return new SpannerHistoryWriter of the database like "projects/p/instances/i/databases/d".
+// It can be the collecting database or the other one. The missing tables are created with tablePrefix.
+// The rows are written with InsertOrUpdate keyed on IntervalEnd and the fingerprint, so writing the same stats again is safe.
+// It works with the emulator when SPANNER_EMULATOR_HOST is set. The skipped stats are logged to the logger, nil logs to stderr.
+func NewSpannerHistoryWriter(
+	ctx context.Context,
+	db string,
+	tablePrefix string,
+	logger *zap.Logger,
+	opts ...option.ClientOption,
+) (*SpannerHistoryWriter, error) {
+	if err := createSpannerHistoryTables(ctx, db, tablePrefix, opts...); err != nil {
+		return nil, fmt.Errorf("failed to create history tables: %w", err)
+	}
+
+	client, err := spanner.NewClient(ctx, db, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if logger == nil {
+		logger = newDefaultLogger()
+	}
+
+	return &SpannerHistoryWriter{
+		client:      client,
+		tablePrefix: tablePrefix,
+		logger:      logger,
+	}, nil
+}
+
+// Write stats collection into the history tables
+func (w *SpannerHistoryWriter) Write(stats []stat) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	mutations := make([]*spanner.Mutation, 0, len(stats))
+	// indexes are the index of the stat of each mutation, to report the written stats
+	indexes := make([]int, 0, len(stats))
+	for i, s := range stats {
+		m, err := w.mutation(s)
+		if err != nil {
+			w.logger.Error("failed to encode stat", zap.String("type", statTypeName(s)), zap.Error(err))
+			continue
+		}
+		if m != nil {
+			mutations = append(mutations, m)
+			indexes = append(indexes, i)
+		}
+	}
+
+	for sent := 0; sent < len(mutations); {
+		n := spannerHistoryMaxRows
+		if n > len(mutations)-sent {
+			n = len(mutations) - sent
+		}
+
+		// each commit is atomic, so the stats before the failed commit are written
+		if _, err := w.client.Apply(ctx, mutations[sent:sent+n]); err != nil {
+			return &PartialWriteError{Written: indexes[sent], Err: fmt.Errorf("failed to write spanner history: %w", err)}
+		}
+		sent += n
+	}
+
+	return nil
+}
+
+// Close the client
+func (w *SpannerHistoryWriter) Close() {
+	w.client.Close()
+}
+
+func (w *SpannerHistoryWriter) mutation(s stat) (*spanner.Mutation, error) {
+	// the snapshots are not written
+	if _, ok := statKeyFields[statTypeName(s)]; !ok {
+		return nil, nil
+	}
+
+	fields := statFields(s)
+	columns := make([]string, 0, len(fields))
+	values := make([]interface{}, 0, len(fields))
+	for _, f := range fields {
+		v, err := spannerHistoryValue(f.value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", f.name, err)
+		}
+		columns = append(columns, f.name)
+		values = append(values, v)
+	}
+
+	return spanner.InsertOrUpdate(spannerHistoryTableName(w.tablePrefix, s), columns, values), nil
+}
+
+// spannerHistoryTableName returns the table name like "QueryStatsHistory"
+func spannerHistoryTableName(prefix string, s stat) string {
+	return prefix + strings.TrimSuffix(statTypeName(s), "Stat") + "StatsHistory"
+}
+
+func createSpannerHistoryTables(ctx context.Context, db string, tablePrefix string, opts ...option.ClientOption) error {
+	admin, err := database.NewDatabaseAdminClient(ctx, opts...)
+	if err != nil {
+		return err
+	}
+	defer admin.Close()
+
+	resp, err := admin.GetDatabaseDdl(ctx, &databasepb.GetDatabaseDdlRequest{Database: db})
+	if err != nil {
+		return err
+	}
+
+	exists := map[string]bool{}
+	for _, stmt := range resp.Statements {
+		if m := spannerCreateTableRegexp.FindStringSubmatch(stmt); m != nil {
+			exists[strings.ToLower(m[1])] = true
+		}
+	}
+
+	var statements []string
+	for _, s := range intervalStats {
+		table := spannerHistoryTableName(tablePrefix, s)
+		if exists[strings.ToLower(table)] {
+			continue
+		}
+		statements = append(statements, spannerHistoryTableDDL(table, s))
+	}
+	if len(statements) == 0 {
+		return nil
+	}
+
+	op, err := admin.UpdateDatabaseDdl(ctx, &databasepb.UpdateDatabaseDdlRequest{
+		Database:   db,
+		Statements: statements,
+	})
+	if err != nil {
+		return err
+	}
+
+	return op.Wait(ctx)
+}
+
+// spannerHistoryTableDDL returns CREATE TABLE statement derived from the stat struct
+func spannerHistoryTableDDL(table string, s stat) string {
+	keys := statKeyFields[statTypeName(s)]
+	isKey := map[string]bool{}
+	for _, k := range keys {
+		isKey[k] = true
+	}
+
+	t := reflect.Indirect(reflect.ValueOf(s)).Type()
+	var columns []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		column := f.Name + " " + spannerColumnType(f.Type)
+		if isKey[f.Name] {
+			column += " NOT NULL"
+		}
+		columns = append(columns, column)
+	}
+
+	return fmt.Sprintf(
+		"CREATE TABLE %s (\n\t%s,\n) PRIMARY KEY (%s)",
+		table,
+		strings.Join(columns, ",\n\t"),
+		strings.Join(keys, ", "),
+	)
+}
+
+func spannerColumnType(t reflect.Type) string {
+	switch t {
+	case timeType:
+		return "TIMESTAMP"
+	case durationType:
+		return "INT64"
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "BOOL"
+	case reflect.Int64:
+		return "INT64"
+	case reflect.Float64:
+		return "FLOAT64"
+	case reflect.String:
+		return "STRING(MAX)"
+	case reflect.Slice:
+		switch t.Elem().Kind() {
+		case reflect.Uint8:
+			return "BYTES(MAX)"
+		case reflect.String:
+			return "ARRAY<STRING(MAX)>"
+		}
+	}
+
+	// JSON encoded like SampleLockRequests
+	return "STRING(MAX)"
+}
+
+// spannerHistoryValue converts the field value to the type of spannerColumnType
+func spannerHistoryValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case time.Duration:
+		return int64(v), nil
+	case bool, int64, float64, string, []byte, []string, time.Time:
+		return v, nil
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return string(b), nil
+}
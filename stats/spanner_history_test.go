@@ -0,0 +1,127 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	instance "cloud.google.com/go/spanner/admin/instance/apiv1"
+	databasepb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+	instancepb "google.golang.org/genproto/googleapis/spanner/admin/instance/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	emulatorProject  = "test-project"
+	emulatorInstance = "test-instance"
+)
+
+// newEmulatorDatabase creates the empty database on the emulator, or skips the test without SPANNER_EMULATOR_HOST
+func newEmulatorDatabase(ctx context.Context, t *testing.T) string {
+	t.Helper()
+
+	if os.Getenv("SPANNER_EMULATOR_HOST") == "" {
+		t.Skip("SPANNER_EMULATOR_HOST is not set")
+	}
+
+	instanceAdmin, err := instance.NewInstanceAdminClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer instanceAdmin.Close()
+
+	iop, err := instanceAdmin.CreateInstance(ctx, &instancepb.CreateInstanceRequest{
+		Parent:     "projects/" + emulatorProject,
+		InstanceId: emulatorInstance,
+		Instance: &instancepb.Instance{
+			Config:      "projects/" + emulatorProject + "/instanceConfigs/emulator-config",
+			DisplayName: emulatorInstance,
+			NodeCount:   1,
+		},
+	})
+	if err == nil {
+		_, err = iop.Wait(ctx)
+	}
+	if err != nil && status.Code(err) != codes.AlreadyExists {
+		t.Fatal(err)
+	}
+
+	databaseAdmin, err := database.NewDatabaseAdminClient(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer databaseAdmin.Close()
+
+	name := fmt.Sprintf("test-%d", time.Now().UnixNano()%1000000000)
+	dop, err := databaseAdmin.CreateDatabase(ctx, &databasepb.CreateDatabaseRequest{
+		Parent:          "projects/" + emulatorProject + "/instances/" + emulatorInstance,
+		CreateStatement: "CREATE DATABASE `" + name + "`",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dop.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	return "projects/" + emulatorProject + "/instances/" + emulatorInstance + "/databases/" + name
+}
+
+func TestSpannerHistoryWriter(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	db := newEmulatorDatabase(ctx, t)
+
+	w, err := NewSpannerHistoryWriter(ctx, db, "Test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	intervalEnd := time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC)
+	stats := []stat{
+		&QueryStat{IntervalEnd: intervalEnd, Text: "SELECT 1", TextFingerprint: 1, ExecutionCount: 10},
+		&QueryStat{IntervalEnd: intervalEnd, Text: "SELECT 2", TextFingerprint: 2, ExecutionCount: 20},
+		&QueryTotalStat{IntervalEnd: intervalEnd, ExecutionCount: 30},
+	}
+
+	// writing the same stats again is safe
+	for i := 0; i < 2; i++ {
+		if err := w.Write(stats); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the tables exist already
+	again, err := NewSpannerHistoryWriter(ctx, db, "Test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again.Close()
+
+	stmt := spanner.NewStatement("SELECT Text FROM TestQueryStatsHistory WHERE IntervalEnd = @t ORDER BY TextFingerprint")
+	stmt.Params["t"] = intervalEnd
+
+	var texts []string
+	err = w.client.Single().Query(ctx, stmt).Do(func(row *spanner.Row) error {
+		var text string
+		if err := row.Columns(&text); err != nil {
+			return err
+		}
+		texts = append(texts, text)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(texts) != 2 || texts[0] != "SELECT 1" || texts[1] != "SELECT 2" {
+		t.Errorf("unexpected rows: %q", texts)
+	}
+}
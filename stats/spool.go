@@ -0,0 +1,257 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const spoolFileExt = ".json"
+
+// SpoolWriterConfig is the configuration of SpoolWriter
+type SpoolWriterConfig struct {
+	// Dir to keep the failed stats
+	Dir string
+	// MaxBytes of the spooled files. The oldest files are removed when exceeded. 0 disables.
+	MaxBytes int64
+	// MaxAge of the spooled files. The older files are removed without replaying. 0 disables.
+	MaxAge time.Duration
+	// Logger for spooling and removing the files. nil logs to stderr.
+	Logger *zap.Logger
+}
+
+// SpoolWriter keeps the stats into the files when the underlying Writer fails,
+// and replays them before the next Write, so short outages of the sink don't leave gaps.
+// Each file is a JSON array of {"type":"QueryStat","data":{...}}.
+type SpoolWriter struct {
+	writer Writer
+	cfg    SpoolWriterConfig
+
+	mu  sync.Mutex
+	seq int
+}
+
+type spoolEnvelope struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// NewSpoolWriter return new SpoolWriter of the writer.
+// The files already in the directory are replayed too, so the stats survive restarting.
+func NewSpoolWriter(writer Writer, cfg SpoolWriterConfig) (*SpoolWriter, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = newDefaultLogger()
+	}
+
+	return &SpoolWriter{
+		writer: writer,
+		cfg:    cfg,
+	}, nil
+}
+
+// Write stats collection to the underlying Writer, or into the spool if failed.
+// It returns error only when the stats could not be spooled.
+func (w *SpoolWriter) Write(stats []stat) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// the spooled stats are older, so they are written first
+	if err := w.replay(); err != nil {
+		return w.spool(stats, err)
+	}
+
+	if err := w.writer.Write(stats); err != nil {
+		// the chunks already written are not spooled, so they are not written twice
+		return w.spool(stats[partiallyWritten(err, stats):], err)
+	}
+
+	return nil
+}
+
+// spool writes stats into the new file
+func (w *SpoolWriter) spool(stats []stat, cause error) error {
+	if len(stats) == 0 {
+		return nil
+	}
+
+	w.seq++
+	name := fmt.Sprintf("%020d-%06d%s", time.Now().UnixNano(), w.seq%1000000, spoolFileExt)
+	if err := w.writeFile(name, stats); err != nil {
+		return fmt.Errorf("failed to spool stats: %w: %v", err, cause)
+	}
+
+	w.cfg.Logger.Warn("spooled stats", zap.String("file", name), zap.Int("stats", len(stats)), zap.Error(cause))
+
+	return w.trim()
+}
+
+// writeFile writes stats into the file atomically, it replaces the file if exists
+func (w *SpoolWriter) writeFile(name string, stats []stat) error {
+	envelopes := make([]spoolEnvelope, 0, len(stats))
+	for _, s := range stats {
+		data, err := json.Marshal(s)
+		if err != nil {
+			w.cfg.Logger.Error("failed to marshal stat", zap.String("type", statTypeName(s)), zap.Error(err))
+			continue
+		}
+		envelopes = append(envelopes, spoolEnvelope{Type: statTypeName(s), Data: data})
+	}
+
+	b, err := json.Marshal(envelopes)
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(w.cfg.Dir, "."+name)
+	if err := ioutil.WriteFile(tmp, b, 0o644); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, filepath.Join(w.cfg.Dir, name))
+}
+
+// replay writes the spooled files in order, and stops at the first failure
+func (w *SpoolWriter) replay() error {
+	files, err := w.files()
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if w.expired(f) {
+			w.cfg.Logger.Warn("removed spooled stats older than max age", zap.String("file", f.Name()), zap.Duration("max_age", w.cfg.MaxAge))
+			if err := os.Remove(filepath.Join(w.cfg.Dir, f.Name())); err != nil {
+				return err
+			}
+			continue
+		}
+
+		stats, err := w.read(f.Name())
+		if err != nil {
+			// broken file can't be replayed forever
+			w.cfg.Logger.Warn("removed broken spooled stats", zap.String("file", f.Name()), zap.Error(err))
+			if err := os.Remove(filepath.Join(w.cfg.Dir, f.Name())); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := w.writer.Write(stats); err != nil {
+			// keep only the rest, with the original time for MaxAge
+			if n := partiallyWritten(err, stats); n > 0 {
+				if err := w.writeFile(f.Name(), stats[n:]); err != nil {
+					return err
+				}
+				if err := os.Chtimes(filepath.Join(w.cfg.Dir, f.Name()), f.ModTime(), f.ModTime()); err != nil {
+					return err
+				}
+			}
+			return err
+		}
+		if err := os.Remove(filepath.Join(w.cfg.Dir, f.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// trim removes the expired files and the oldest files over MaxBytes
+func (w *SpoolWriter) trim() error {
+	files, err := w.files()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, f := range files {
+		total += f.Size()
+	}
+
+	for _, f := range files {
+		if !w.expired(f) && (w.cfg.MaxBytes <= 0 || total <= w.cfg.MaxBytes) {
+			break
+		}
+
+		w.cfg.Logger.Warn("removed spooled stats over the limit", zap.String("file", f.Name()), zap.Int64("max_bytes", w.cfg.MaxBytes))
+		if err := os.Remove(filepath.Join(w.cfg.Dir, f.Name())); err != nil {
+			return err
+		}
+		total -= f.Size()
+	}
+
+	return nil
+}
+
+func (w *SpoolWriter) expired(f os.FileInfo) bool {
+	return w.cfg.MaxAge > 0 && time.Since(f.ModTime()) > w.cfg.MaxAge
+}
+
+// files returns the spooled files from the oldest
+func (w *SpoolWriter) files() ([]os.FileInfo, error) {
+	entries, err := ioutil.ReadDir(w.cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]os.FileInfo, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") || !strings.HasSuffix(e.Name(), spoolFileExt) {
+			continue
+		}
+		files = append(files, e)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name() < files[j].Name() })
+
+	return files, nil
+}
+
+func (w *SpoolWriter) read(name string) ([]stat, error) {
+	b, err := ioutil.ReadFile(filepath.Join(w.cfg.Dir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	var envelopes []spoolEnvelope
+	if err := json.Unmarshal(b, &envelopes); err != nil {
+		return nil, err
+	}
+
+	stats := make([]stat, 0, len(envelopes))
+	for _, e := range envelopes {
+		s, ok := newStatOfType(e.Type)
+		if !ok {
+			return nil, fmt.Errorf("unknown stat type: %s", e.Type)
+		}
+		if err := json.Unmarshal(e.Data, s); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+
+	return stats, nil
+}
+
+// newStatOfType returns the new stat of the struct name like "QueryStat"
+func newStatOfType(name string) (stat, bool) {
+	for _, s := range append(append([]stat{}, intervalStats...), snapshotStats...) {
+		if statTypeName(s) == name {
+			return reflect.New(reflect.Indirect(reflect.ValueOf(s)).Type()).Interface().(stat), true
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,219 @@
+package stats
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// partialWriter writes only the first n stats and fails while n is positive, like a chunked sink
+type partialWriter struct {
+	n       int
+	written []stat
+}
+
+func (w *partialWriter) Write(stats []stat) error {
+	if w.n <= 0 || w.n >= len(stats) {
+		w.written = append(w.written, stats...)
+		return nil
+	}
+
+	w.written = append(w.written, stats[:w.n]...)
+	return &PartialWriteError{Written: w.n, Err: os.ErrDeadlineExceeded}
+}
+
+func newSpoolTestWriter(t *testing.T, writer Writer, dir string) *SpoolWriter {
+	t.Helper()
+
+	w, err := NewSpoolWriter(writer, SpoolWriterConfig{Dir: dir, Logger: zap.NewNop()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return w
+}
+
+func spoolTestStats(fingerprints ...int64) []stat {
+	stats := make([]stat, 0, len(fingerprints))
+	for _, f := range fingerprints {
+		stats = append(stats, &QueryStat{Text: "SELECT 1", TextFingerprint: f})
+	}
+
+	return stats
+}
+
+func spoolTestFingerprints(stats []stat) []int64 {
+	fingerprints := []int64{}
+	for _, s := range stats {
+		f, _ := statFingerprint(s)
+		fingerprints = append(fingerprints, f)
+	}
+
+	return fingerprints
+}
+
+func spoolTestFiles(t *testing.T, w *SpoolWriter) []os.FileInfo {
+	t.Helper()
+
+	files, err := w.files()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return files
+}
+
+func writeSpoolTestStats(t *testing.T, w Writer, fingerprints ...int64) {
+	t.Helper()
+
+	if err := w.Write(spoolTestStats(fingerprints...)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSpoolWriterReplay(t *testing.T) {
+	out := &failingWriter{fail: true}
+	w := newSpoolTestWriter(t, out, t.TempDir())
+
+	writeSpoolTestStats(t, w, 1, 2)
+	writeSpoolTestStats(t, w, 3)
+	if files := spoolTestFiles(t, w); len(files) != 2 {
+		t.Fatalf("unexpected spooled files: %d", len(files))
+	}
+
+	// the spooled stats are written in order before the new ones
+	out.fail = false
+	writeSpoolTestStats(t, w, 4)
+
+	if got := spoolTestFingerprints(out.written); !reflect.DeepEqual(got, []int64{1, 2, 3, 4}) {
+		t.Errorf("unexpected written: %v", got)
+	}
+	if files := spoolTestFiles(t, w); len(files) != 0 {
+		t.Errorf("replayed files are left: %d", len(files))
+	}
+}
+
+func TestSpoolWriterReplayAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	writeSpoolTestStats(t, newSpoolTestWriter(t, &failingWriter{fail: true}, dir), 1, 2)
+
+	out := &failingWriter{}
+	writeSpoolTestStats(t, newSpoolTestWriter(t, out, dir), 3)
+
+	if got := spoolTestFingerprints(out.written); !reflect.DeepEqual(got, []int64{1, 2, 3}) {
+		t.Errorf("unexpected written: %v", got)
+	}
+}
+
+func TestSpoolWriterPartialWrite(t *testing.T) {
+	out := &partialWriter{n: 1}
+	w := newSpoolTestWriter(t, out, t.TempDir())
+
+	// only the stats after the written chunk are spooled
+	writeSpoolTestStats(t, w, 1, 2, 3)
+
+	// the replay fails partway again, and the file keeps only the rest
+	writeSpoolTestStats(t, w, 4)
+	files := spoolTestFiles(t, w)
+	if len(files) != 2 {
+		t.Fatalf("unexpected spooled files: %d", len(files))
+	}
+	stats, err := w.read(files[0].Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := spoolTestFingerprints(stats); !reflect.DeepEqual(got, []int64{3}) {
+		t.Errorf("unexpected rest of the file: %v", got)
+	}
+
+	out.n = 0
+	writeSpoolTestStats(t, w, 5)
+
+	if got := spoolTestFingerprints(out.written); !reflect.DeepEqual(got, []int64{1, 2, 3, 4, 5}) {
+		t.Errorf("unexpected written: %v", got)
+	}
+}
+
+func TestSpoolWriterMaxBytes(t *testing.T) {
+	out := &failingWriter{fail: true}
+	w := newSpoolTestWriter(t, out, t.TempDir())
+
+	writeSpoolTestStats(t, w, 1)
+	files := spoolTestFiles(t, w)
+	if len(files) != 1 {
+		t.Fatalf("unexpected spooled files: %d", len(files))
+	}
+
+	// room for two files, the oldest one is removed
+	w.cfg.MaxBytes = files[0].Size()*2 + 1
+	writeSpoolTestStats(t, w, 2)
+	writeSpoolTestStats(t, w, 3)
+	if files := spoolTestFiles(t, w); len(files) != 2 {
+		t.Fatalf("unexpected spooled files: %d", len(files))
+	}
+
+	out.fail = false
+	writeSpoolTestStats(t, w, 4)
+
+	if got := spoolTestFingerprints(out.written); !reflect.DeepEqual(got, []int64{2, 3, 4}) {
+		t.Errorf("unexpected written: %v", got)
+	}
+}
+
+func TestSpoolWriterMaxAge(t *testing.T) {
+	out := &failingWriter{fail: true}
+	w := newSpoolTestWriter(t, out, t.TempDir())
+	w.cfg.MaxAge = time.Hour
+
+	writeSpoolTestStats(t, w, 1)
+	writeSpoolTestStats(t, w, 2)
+
+	files := spoolTestFiles(t, w)
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(filepath.Join(w.cfg.Dir, files[0].Name()), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	out.fail = false
+	writeSpoolTestStats(t, w, 3)
+
+	if got := spoolTestFingerprints(out.written); !reflect.DeepEqual(got, []int64{2, 3}) {
+		t.Errorf("unexpected written: %v", got)
+	}
+	if files := spoolTestFiles(t, w); len(files) != 0 {
+		t.Errorf("expired files are left: %d", len(files))
+	}
+}
+
+func TestSpoolWriterSkipsBrokenFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"00000000000000000001-000001.json": `{"broken`,
+		"00000000000000000002-000002.json": `[{"type":"UnknownStat","data":{}}]`,
+		"00000000000000000003-000003.json": `[{"type":"QueryStat","data":{"TextFingerprint":1}}]`,
+		// not spooled files are ignored
+		".00000000000000000004-000004.json": `{"broken`,
+		"README.txt":                        "not spooled",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := &failingWriter{}
+	w := newSpoolTestWriter(t, out, dir)
+	writeSpoolTestStats(t, w, 2)
+
+	if got := spoolTestFingerprints(out.written); !reflect.DeepEqual(got, []int64{1, 2}) {
+		t.Errorf("unexpected written: %v", got)
+	}
+	if files := spoolTestFiles(t, w); len(files) != 0 {
+		t.Errorf("broken files are left: %d", len(files))
+	}
+}
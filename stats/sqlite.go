@@ -0,0 +1,246 @@
+package stats
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	// pure Go driver, the binary is built without cgo
+	_ "modernc.org/sqlite"
+)
+
+// sqliteTimeFormat is the format of SQLite date and time functions, so the columns can be compared with them
+const sqliteTimeFormat = "2006-01-02 15:04:05.000"
+
+// sqliteViews are the views for the common questions
+var sqliteViews = []string{
+	`CREATE VIEW IF NOT EXISTS top_cpu_fingerprints_24h AS
+SELECT
+	text_fingerprint,
+	MAX(text) AS text,
+	SUM(execution_count) AS execution_count,
+	SUM(avg_cpu_seconds * execution_count) AS total_cpu_seconds,
+	SUM(avg_cpu_seconds * execution_count) / SUM(execution_count) AS avg_cpu_seconds,
+	SUM(avg_latency_seconds * execution_count) / SUM(execution_count) AS avg_latency_seconds
+FROM query_stats
+WHERE interval_end > strftime('%Y-%m-%d %H:%M:%f', 'now', '-1 day')
+GROUP BY text_fingerprint
+ORDER BY total_cpu_seconds DESC`,
+
+	`CREATE VIEW IF NOT EXISTS transaction_abort_rate_by_shape AS
+SELECT
+	read_columns,
+	write_constructive_columns,
+	write_delete_tables,
+	COUNT(DISTINCT fprint) AS fprints,
+	SUM(commit_attempt_count) AS commit_attempt_count,
+	SUM(commit_abort_count) AS commit_abort_count,
+	CAST(SUM(commit_abort_count) AS REAL) / SUM(commit_attempt_count) AS abort_rate
+FROM transaction_stats
+GROUP BY read_columns, write_constructive_columns, write_delete_tables
+ORDER BY abort_rate DESC`,
+}
+
+// SQLiteWriter persists stats into the SQLite file. Each stat family has the table like "query_stats"
+// with the snake_case fields of the stat. Times are "2006-01-02 15:04:05.000" in UTC, durations are nanoseconds,
+// and array fields like ReadColumns and SampleLockRequests are JSON encoded, so json_each() can expand them.
+type SQLiteWriter struct {
+	db *sql.DB
+	mu sync.Mutex
+}
+
+// NewSQLiteWriter return new SQLiteWriter of the file. The tables, indexes and views are created if missing.
+func NewSQLiteWriter(path string) (*SQLiteWriter, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	statements := []string{
+		"PRAGMA journal_mode=WAL",
+		"PRAGMA busy_timeout=5000",
+	}
+	for _, s := range append(append([]stat{}, intervalStats...), snapshotStats...) {
+		statements = append(statements, sqliteTableDDL(s)...)
+	}
+	statements = append(statements, sqliteViews...)
+
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to initialize sqlite: %w", err)
+		}
+	}
+
+	return &SQLiteWriter{
+		db: db,
+	}, nil
+}
+
+// Write stats collection into the tables
+func (w *SQLiteWriter) Write(stats []stat) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.write(stats); err != nil {
+		return fmt.Errorf("failed to write sqlite: %w", err)
+	}
+
+	return nil
+}
+
+func (w *SQLiteWriter) write(stats []stat) error {
+	tx, err := w.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, s := range stats {
+		fields := statFields(s)
+		columns := make([]string, 0, len(fields))
+		placeholders := make([]string, 0, len(fields))
+		values := make([]interface{}, 0, len(fields))
+		for _, f := range fields {
+			v, err := sqliteValue(f.value)
+			if err != nil {
+				return fmt.Errorf("failed to encode %s: %w", f.name, err)
+			}
+			columns = append(columns, toSnakeCase(f.name))
+			placeholders = append(placeholders, "?")
+			values = append(values, v)
+		}
+
+		// the interval stats are replaced by the unique index, when the same period is written again
+		query := fmt.Sprintf(
+			"INSERT OR REPLACE INTO %s (%s) VALUES (%s)",
+			sqliteTableName(s),
+			strings.Join(columns, ", "),
+			strings.Join(placeholders, ", "),
+		)
+		if _, err := tx.Exec(query, values...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Close the database
+func (w *SQLiteWriter) Close() error {
+	return w.db.Close()
+}
+
+// sqliteTableName returns the table name like "query_stats"
+func sqliteTableName(s stat) string {
+	return statFamilyName(s) + "_stats"
+}
+
+// sqliteTableDDL returns the statements to create the table and the indexes derived from the stat struct
+func sqliteTableDDL(s stat) []string {
+	table := sqliteTableName(s)
+	t := reflect.Indirect(reflect.ValueOf(s)).Type()
+
+	var columns []string
+	has := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		columns = append(columns, toSnakeCase(f.Name)+" "+sqliteColumnType(f.Type))
+		has[f.Name] = true
+	}
+
+	statements := []string{
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (\n\t%s\n)", table, strings.Join(columns, ",\n\t")),
+	}
+
+	timeField := "IntervalEnd"
+	if !has[timeField] {
+		timeField = "CollectedAt"
+	}
+	statements = append(statements, fmt.Sprintf(
+		"CREATE INDEX IF NOT EXISTS %s_%s ON %s (%s)",
+		table, toSnakeCase(timeField), table, toSnakeCase(timeField),
+	))
+
+	// the fingerprints are indexed with IntervalEnd or CollectedAt
+	for _, name := range statFingerprintFields {
+		if has[name] {
+			statements = append(statements, fmt.Sprintf(
+				"CREATE INDEX IF NOT EXISTS %s_%s ON %s (%s, %s)",
+				table, toSnakeCase(name), table, toSnakeCase(name), toSnakeCase(timeField),
+			))
+		}
+	}
+
+	if keys, ok := statKeyFields[t.Name()]; ok {
+		columns := make([]string, 0, len(keys))
+		for _, k := range keys {
+			columns = append(columns, toSnakeCase(k))
+		}
+		statements = append(statements, fmt.Sprintf(
+			"CREATE UNIQUE INDEX IF NOT EXISTS %s_key ON %s (%s)",
+			table, table, strings.Join(columns, ", "),
+		))
+	}
+
+	return statements
+}
+
+func sqliteColumnType(t reflect.Type) string {
+	switch t {
+	case timeType:
+		return "TEXT"
+	case durationType:
+		return "INTEGER"
+	}
+
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int64:
+		return "INTEGER"
+	case reflect.Float64:
+		return "REAL"
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "BLOB"
+		}
+	}
+
+	return "TEXT"
+}
+
+// sqliteValue converts the field value to the type of sqliteColumnType
+func sqliteValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC().Format(sqliteTimeFormat), nil
+	case time.Duration:
+		return int64(v), nil
+	case bool:
+		if v {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case int64, float64, string, []byte:
+		return v, nil
+	}
+
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	// nil slices are written as an empty array rather than null
+	if string(b) == "null" {
+		return "[]", nil
+	}
+
+	return string(b), nil
+}
@@ -0,0 +1,66 @@
+package stats
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSQLiteWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w, err := NewSQLiteWriter(filepath.Join(dir, "stats.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	intervalEnd := time.Now().UTC().Truncate(time.Minute)
+	stats := []stat{
+		&QueryStat{IntervalEnd: intervalEnd, Text: "SELECT 1", TextFingerprint: 1, ExecutionCount: 10, AvgCPUSeconds: 1},
+		&QueryStat{IntervalEnd: intervalEnd, Text: "SELECT 2", TextFingerprint: 2, ExecutionCount: 20, AvgCPUSeconds: 2},
+	}
+
+	// the same period is replaced by the unique index
+	for i := 0; i < 2; i++ {
+		if err := w.Write(stats); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var count int
+	if err := w.db.QueryRow("SELECT COUNT(*) FROM query_stats WHERE interval_end = ?", intervalEnd.Format(sqliteTimeFormat)).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("unexpected rows: %d", count)
+	}
+
+	rows, err := w.db.Query("SELECT text_fingerprint, total_cpu_seconds FROM top_cpu_fingerprints_24h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var got [][2]float64
+	for rows.Next() {
+		var fingerprint, cpu float64
+		if err := rows.Scan(&fingerprint, &cpu); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, [2]float64{fingerprint, cpu})
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 2 || got[0] != [2]float64{2, 40} || got[1] != [2]float64{1, 10} {
+		t.Errorf("unexpected top cpu fingerprints: %v", got)
+	}
+}
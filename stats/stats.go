@@ -41,11 +41,23 @@ func (s StatDuration) Duration() time.Duration {
 	return 1 * time.Minute
 }
 
+// Retention returns how long Spanner keeps the stats
+func (s StatDuration) Retention() time.Duration {
+	switch s {
+	case StatDuration10Min:
+		return 4 * 24 * time.Hour
+	case StatDurationHour:
+		return 30 * 24 * time.Hour
+	}
+	return 6 * time.Hour
+}
+
 type stat interface {
 	getIntervalEnd() time.Time
 }
 
-type statGetter func(context.Context, StatDuration, time.Time) []stat
+// statGetter returns stats of intervals in (from, to], sorted by IntervalEnd in ascending order
+type statGetter func(ctx context.Context, t StatDuration, from, to time.Time) ([]stat, error)
 
 // QueryStat track the queries with the highest CPU usage during a specific time period
 // followed https://cloud.google.com/spanner/docs/query-stats-tables
@@ -67,15 +79,17 @@ func (q *QueryStat) getIntervalEnd() time.Time {
 }
 
 // GetQueryStats returns Stat collection with specific time period
-func (c *Client) getQueryStats(ctx context.Context, t StatDuration, lastIntervalEnd time.Time) []stat {
+func (c *Client) getQueryStats(ctx context.Context, t StatDuration, from, to time.Time) ([]stat, error) {
 	txn, err := c.spannerClient.BatchReadOnlyTransaction(ctx, spanner.ExactStaleness(time.Minute))
 	if err != nil {
-		return nil
+		return nil, err
 	}
 	defer txn.Close()
 
 	stmt := spanner.NewStatement(fmt.Sprintf(
 		`SELECT text,
+	text_truncated,
+	text_fingerprint,
 	interval_end,
 	execution_count,
 	avg_latency_seconds,
@@ -84,11 +98,12 @@ func (c *Client) getQueryStats(ctx context.Context, t StatDuration, lastInterval
 	avg_rows_scanned,
 	avg_cpu_seconds
 FROM spanner_sys.query_stats_top_%s
-WHERE interval_end > @last_interval_end
-ORDER BY interval_end DESC;`,
+WHERE interval_end > @from AND interval_end <= @to
+ORDER BY interval_end ASC;`,
 		t.String(),
 	))
-	stmt.Params["last_interval_end"] = lastIntervalEnd
+	stmt.Params["from"] = from
+	stmt.Params["to"] = to
 
 	iter := txn.Query(ctx, stmt)
 	defer iter.Stop()
@@ -101,20 +116,20 @@ ORDER BY interval_end DESC;`,
 			if err == iterator.Done {
 				break
 			}
-			return nil
+			return nil, err
 		}
 
 		var b QueryStat
 		err = row.ToStruct(&b)
 		if err != nil {
-			return nil
+			return nil, err
 		}
 
 		b.Text = strings.TrimSpace(b.Text)
 		results = append(results, &b)
 	}
 
-	return results
+	return results, nil
 }
 
 // TransactionStat track the transactions during a specific time period
@@ -139,11 +154,10 @@ func (q *TransactionStat) getIntervalEnd() time.Time {
 }
 
 // GetTransactionStats returns stat collection with specific time period
-func (c *Client) getTransactionStats(ctx context.Context, t StatDuration, lastIntervalEnd time.Time) []stat {
+func (c *Client) getTransactionStats(ctx context.Context, t StatDuration, from, to time.Time) ([]stat, error) {
 	txn, err := c.spannerClient.BatchReadOnlyTransaction(ctx, spanner.ExactStaleness(time.Minute))
 	if err != nil {
-		fmt.Printf("%+v", err)
-		return nil
+		return nil, err
 	}
 	defer txn.Close()
 
@@ -162,11 +176,12 @@ func (c *Client) getTransactionStats(ctx context.Context, t StatDuration, lastIn
 	avg_commit_latency_seconds,
 	avg_bytes,
 FROM spanner_sys.txn_stats_top_%s
-WHERE interval_end > @last_interval_end
-ORDER BY interval_end DESC;`,
+WHERE interval_end > @from AND interval_end <= @to
+ORDER BY interval_end ASC;`,
 		t.String(),
 	))
-	stmt.Params["last_interval_end"] = lastIntervalEnd
+	stmt.Params["from"] = from
+	stmt.Params["to"] = to
 
 	iter := txn.Query(ctx, stmt)
 	defer iter.Stop()
@@ -179,21 +194,19 @@ ORDER BY interval_end DESC;`,
 			if err == iterator.Done {
 				break
 			}
-			fmt.Printf("%+v\n", err)
-			return nil
+			return nil, err
 		}
 
 		var b TransactionStat
 		err = row.ToStruct(&b)
 		if err != nil {
-			fmt.Printf("%+v\n", err)
-			return nil
+			return nil, err
 		}
 
 		results = append(results, &b)
 	}
 
-	return results
+	return results, nil
 }
 
 // LockStat track the lock columns during a specific time period
@@ -213,11 +226,10 @@ func (q *LockStat) getIntervalEnd() time.Time {
 }
 
 // GetLockStats returns Stat collection with specific time period
-func (c *Client) getLockStats(ctx context.Context, t StatDuration, lastIntervalEnd time.Time) []stat {
+func (c *Client) getLockStats(ctx context.Context, t StatDuration, from, to time.Time) ([]stat, error) {
 	txn, err := c.spannerClient.BatchReadOnlyTransaction(ctx, spanner.ExactStaleness(time.Minute))
 	if err != nil {
-		fmt.Printf("%+v", err)
-		return nil
+		return nil, err
 	}
 	defer txn.Close()
 
@@ -228,11 +240,12 @@ func (c *Client) getLockStats(ctx context.Context, t StatDuration, lastIntervalE
 	lock_wait_seconds,
 	sample_lock_requests
 FROM spanner_sys.lock_stats_top_%s
-WHERE interval_end > @last_interval_end
-ORDER BY interval_end DESC;`,
+WHERE interval_end > @from AND interval_end <= @to
+ORDER BY interval_end ASC;`,
 		t.String(),
 	))
-	stmt.Params["last_interval_end"] = lastIntervalEnd
+	stmt.Params["from"] = from
+	stmt.Params["to"] = to
 
 	iter := txn.Query(ctx, stmt)
 	defer iter.Stop()
@@ -245,19 +258,342 @@ ORDER BY interval_end DESC;`,
 			if err == iterator.Done {
 				break
 			}
-			fmt.Printf("%+v\n", err)
-			return nil
+			return nil, err
 		}
 
 		var b LockStat
 		err = row.ToStruct(&b)
 		if err != nil {
-			fmt.Printf("%+v\n", err)
-			return nil
+			return nil, err
+		}
+
+		results = append(results, &b)
+	}
+
+	return results, nil
+}
+
+// ReadStat track the reads during a specific time period
+// followed https://cloud.google.com/spanner/docs/introspection/read-statistics
+type ReadStat struct {
+	IntervalEnd                  time.Time `spanner:"INTERVAL_END"`
+	ReadColumns                  []string  `spanner:"READ_COLUMNS"`
+	Fprint                       int64     `spanner:"FPRINT"`
+	ExecutionCount               int64     `spanner:"EXECUTION_COUNT"`
+	AvgRows                      float64   `spanner:"AVG_ROWS"`
+	AvgBytes                     float64   `spanner:"AVG_BYTES"`
+	AvgCPUSeconds                float64   `spanner:"AVG_CPU_SECONDS"`
+	AvgLockingDelaySeconds       float64   `spanner:"AVG_LOCKING_DELAY_SECONDS"`
+	AvgClientWaitSeconds         float64   `spanner:"AVG_CLIENT_WAIT_SECONDS"`
+	AvgLeaderRefreshDelaySeconds float64   `spanner:"AVG_LEADER_REFRESH_DELAY_SECONDS"`
+}
+
+func (q *ReadStat) getIntervalEnd() time.Time {
+	return q.IntervalEnd
+}
+
+// GetReadStats returns Stat collection with specific time period
+func (c *Client) getReadStats(ctx context.Context, t StatDuration, from, to time.Time) ([]stat, error) {
+	txn, err := c.spannerClient.BatchReadOnlyTransaction(ctx, spanner.ExactStaleness(time.Minute))
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Close()
+
+	stmt := spanner.NewStatement(fmt.Sprintf(
+		`SELECT
+	interval_end,
+	read_columns,
+	fprint,
+	execution_count,
+	avg_rows,
+	avg_bytes,
+	avg_cpu_seconds,
+	avg_locking_delay_seconds,
+	avg_client_wait_seconds,
+	avg_leader_refresh_delay_seconds
+FROM spanner_sys.read_stats_top_%s
+WHERE interval_end > @from AND interval_end <= @to
+ORDER BY interval_end ASC;`,
+		t.String(),
+	))
+	stmt.Params["from"] = from
+	stmt.Params["to"] = to
+
+	iter := txn.Query(ctx, stmt)
+	defer iter.Stop()
+
+	var results []stat
+
+	for {
+		row, err := iter.Next()
+		if err != nil {
+			if err == iterator.Done {
+				break
+			}
+			return nil, err
+		}
+
+		var b ReadStat
+		err = row.ToStruct(&b)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, &b)
+	}
+
+	return results, nil
+}
+
+// QueryTotalStat track the aggregated statistics of all queries during a specific time period
+// followed https://cloud.google.com/spanner/docs/introspection/query-statistics
+type QueryTotalStat struct {
+	IntervalEnd       time.Time `spanner:"INTERVAL_END"`
+	ExecutionCount    int64     `spanner:"EXECUTION_COUNT"`
+	AvgLatencySeconds float64   `spanner:"AVG_LATENCY_SECONDS"`
+	AvgRows           float64   `spanner:"AVG_ROWS"`
+	AvgBytes          float64   `spanner:"AVG_BYTES"`
+	AvgRowsScanned    float64   `spanner:"AVG_ROWS_SCANNED"`
+	AvgCPUSeconds     float64   `spanner:"AVG_CPU_SECONDS"`
+}
+
+func (q *QueryTotalStat) getIntervalEnd() time.Time {
+	return q.IntervalEnd
+}
+
+// GetQueryTotalStats returns Stat collection with specific time period
+func (c *Client) getQueryTotalStats(ctx context.Context, t StatDuration, from, to time.Time) ([]stat, error) {
+	txn, err := c.spannerClient.BatchReadOnlyTransaction(ctx, spanner.ExactStaleness(time.Minute))
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Close()
+
+	stmt := spanner.NewStatement(fmt.Sprintf(
+		`SELECT
+	interval_end,
+	execution_count,
+	avg_latency_seconds,
+	avg_rows,
+	avg_bytes,
+	avg_rows_scanned,
+	avg_cpu_seconds
+FROM spanner_sys.query_stats_total_%s
+WHERE interval_end > @from AND interval_end <= @to
+ORDER BY interval_end ASC;`,
+		t.String(),
+	))
+	stmt.Params["from"] = from
+	stmt.Params["to"] = to
+
+	iter := txn.Query(ctx, stmt)
+	defer iter.Stop()
+
+	var results []stat
+
+	for {
+		row, err := iter.Next()
+		if err != nil {
+			if err == iterator.Done {
+				break
+			}
+			return nil, err
+		}
+
+		var b QueryTotalStat
+		err = row.ToStruct(&b)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, &b)
+	}
+
+	return results, nil
+}
+
+// TransactionTotalStat track the aggregated statistics of all transactions during a specific time period
+// followed https://cloud.google.com/spanner/docs/introspection/transaction-statistics
+type TransactionTotalStat struct {
+	IntervalEnd                   time.Time `spanner:"INTERVAL_END"`
+	CommitAttemptCount            int64     `spanner:"COMMIT_ATTEMPT_COUNT"`
+	CommitFailedPreconditionCount int64     `spanner:"COMMIT_FAILED_PRECONDITION_COUNT"`
+	CommitAbortCount              int64     `spanner:"COMMIT_ABORT_COUNT"`
+	AvgParticipants               float64   `spanner:"AVG_PARTICIPANTS"`
+	AvgTotalLatencySeconds        float64   `spanner:"AVG_TOTAL_LATENCY_SECONDS"`
+	AvgCommitLatencySeconds       float64   `spanner:"AVG_COMMIT_LATENCY_SECONDS"`
+	AvgBytes                      float64   `spanner:"AVG_BYTES"`
+}
+
+func (q *TransactionTotalStat) getIntervalEnd() time.Time {
+	return q.IntervalEnd
+}
+
+// GetTransactionTotalStats returns Stat collection with specific time period
+func (c *Client) getTransactionTotalStats(ctx context.Context, t StatDuration, from, to time.Time) ([]stat, error) {
+	txn, err := c.spannerClient.BatchReadOnlyTransaction(ctx, spanner.ExactStaleness(time.Minute))
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Close()
+
+	stmt := spanner.NewStatement(fmt.Sprintf(
+		`SELECT
+	interval_end,
+	commit_attempt_count,
+	commit_failed_precondition_count,
+	commit_abort_count,
+	avg_participants,
+	avg_total_latency_seconds,
+	avg_commit_latency_seconds,
+	avg_bytes
+FROM spanner_sys.txn_stats_total_%s
+WHERE interval_end > @from AND interval_end <= @to
+ORDER BY interval_end ASC;`,
+		t.String(),
+	))
+	stmt.Params["from"] = from
+	stmt.Params["to"] = to
+
+	iter := txn.Query(ctx, stmt)
+	defer iter.Stop()
+
+	var results []stat
+
+	for {
+		row, err := iter.Next()
+		if err != nil {
+			if err == iterator.Done {
+				break
+			}
+			return nil, err
+		}
+
+		var b TransactionTotalStat
+		err = row.ToStruct(&b)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, &b)
+	}
+
+	return results, nil
+}
+
+// LockTotalStat track the aggregated lock wait time of the whole database during a specific time period
+// followed https://cloud.google.com/spanner/docs/introspection/lock-statistics
+type LockTotalStat struct {
+	IntervalEnd          time.Time `spanner:"INTERVAL_END"`
+	TotalLockWaitSeconds float64   `spanner:"TOTAL_LOCK_WAIT_SECONDS"`
+}
+
+func (q *LockTotalStat) getIntervalEnd() time.Time {
+	return q.IntervalEnd
+}
+
+// GetLockTotalStats returns Stat collection with specific time period
+func (c *Client) getLockTotalStats(ctx context.Context, t StatDuration, from, to time.Time) ([]stat, error) {
+	txn, err := c.spannerClient.BatchReadOnlyTransaction(ctx, spanner.ExactStaleness(time.Minute))
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Close()
+
+	stmt := spanner.NewStatement(fmt.Sprintf(
+		`SELECT
+	interval_end,
+	total_lock_wait_seconds
+FROM spanner_sys.lock_stats_total_%s
+WHERE interval_end > @from AND interval_end <= @to
+ORDER BY interval_end ASC;`,
+		t.String(),
+	))
+	stmt.Params["from"] = from
+	stmt.Params["to"] = to
+
+	iter := txn.Query(ctx, stmt)
+	defer iter.Stop()
+
+	var results []stat
+
+	for {
+		row, err := iter.Next()
+		if err != nil {
+			if err == iterator.Done {
+				break
+			}
+			return nil, err
+		}
+
+		var b LockTotalStat
+		err = row.ToStruct(&b)
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, &b)
+	}
+
+	return results, nil
+}
+
+// TableSizeStat track the used bytes of each table
+// followed https://cloud.google.com/spanner/docs/introspection/table-sizes-statistics
+// Note that Spanner only provides this table at the hourly granularity, and it does not contain row counts.
+type TableSizeStat struct {
+	IntervalEnd time.Time `spanner:"INTERVAL_END"`
+	TableName   string    `spanner:"TABLE_NAME"`
+	UsedBytes   float64   `spanner:"USED_BYTES"`
+}
+
+func (q *TableSizeStat) getIntervalEnd() time.Time {
+	return q.IntervalEnd
+}
+
+// GetTableSizeStats returns Stat collection of hourly table sizes. StatDuration is ignored.
+func (c *Client) getTableSizeStats(ctx context.Context, _ StatDuration, from, to time.Time) ([]stat, error) {
+	txn, err := c.spannerClient.BatchReadOnlyTransaction(ctx, spanner.ExactStaleness(time.Minute))
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Close()
+
+	stmt := spanner.NewStatement(
+		`SELECT
+	interval_end,
+	table_name,
+	used_bytes
+FROM spanner_sys.table_sizes_stats_1hour
+WHERE interval_end > @from AND interval_end <= @to
+ORDER BY interval_end ASC;`,
+	)
+	stmt.Params["from"] = from
+	stmt.Params["to"] = to
+
+	iter := txn.Query(ctx, stmt)
+	defer iter.Stop()
+
+	var results []stat
+
+	for {
+		row, err := iter.Next()
+		if err != nil {
+			if err == iterator.Done {
+				break
+			}
+			return nil, err
+		}
+
+		var b TableSizeStat
+		err = row.ToStruct(&b)
+		if err != nil {
+			return nil, err
 		}
 
 		results = append(results, &b)
 	}
 
-	return results
+	return results, nil
 }
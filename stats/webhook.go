@@ -0,0 +1,207 @@
+package stats
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	webhookDefaultMaxBatchSize = 1000
+	// WebhookSignatureHeader has "sha256=<hex>" of HMAC-SHA256 of the request body with the secret
+	WebhookSignatureHeader = "X-Signature-256"
+)
+
+// WebhookWriterConfig is the configuration of the webhook Writer
+type WebhookWriterConfig struct {
+	// URL to POST
+	URL string
+	// Headers of the requests, like Authorization
+	Headers map[string]string
+	// Secret signs the request body if not empty, see WebhookSignatureHeader
+	Secret string
+	// Gzip compresses the request body
+	Gzip bool
+	// MaxBatchSize is the max stats in a request. 0 is 1000.
+	MaxBatchSize int
+	// RetryAttempts is the max attempts of a request, includes the first one
+	RetryAttempts int
+	// RetryBackoff is the wait before the first retry, and doubled every retry
+	RetryBackoff time.Duration
+	// Client to send the requests
+	Client *http.Client
+	// Context cancels the requests and the waits between the retries, like on shutdown. nil never cancels.
+	Context context.Context
+	// Logger for the retries. nil logs to stderr.
+	Logger *zap.Logger
+}
+
+type webhookWriter struct {
+	cfg WebhookWriterConfig
+}
+
+// NewWebhookWriter return new Writer which POSTs stats as JSON like {"stats":[{...},{...}]}.
+// Each stat is the same object as JSONLWriter writes.
+// Network errors, 429 and 5xx responses are retried with exponential backoff.
+func NewWebhookWriter(cfg WebhookWriterConfig) (Writer, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("webhook URL is empty")
+	}
+	if cfg.MaxBatchSize <= 0 {
+		cfg.MaxBatchSize = webhookDefaultMaxBatchSize
+	}
+	if cfg.RetryAttempts <= 0 {
+		cfg.RetryAttempts = 1
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	if cfg.Context == nil {
+		cfg.Context = context.Background()
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = newDefaultLogger()
+	}
+
+	return &webhookWriter{
+		cfg: cfg,
+	}, nil
+}
+
+func (w *webhookWriter) Write(stats []stat) error {
+	for sent := 0; sent < len(stats); {
+		n := w.cfg.MaxBatchSize
+		if n > len(stats)-sent {
+			n = len(stats) - sent
+		}
+
+		if err := w.sendWithRetry(stats[sent : sent+n]); err != nil {
+			return &PartialWriteError{Written: sent, Err: fmt.Errorf("failed to send webhook: %w", err)}
+		}
+		sent += n
+	}
+
+	return nil
+}
+
+func (w *webhookWriter) sendWithRetry(stats []stat) error {
+	body, err := w.body(stats)
+	if err != nil {
+		return err
+	}
+
+	backoff := w.cfg.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		retryable, err := w.send(body)
+		if err == nil || !retryable || attempt >= w.cfg.RetryAttempts {
+			return err
+		}
+
+		w.cfg.Logger.Warn(
+			"retry sending webhook",
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", backoff),
+			zap.Error(err),
+		)
+
+		t := time.NewTimer(backoff)
+		select {
+		case <-t.C:
+		case <-w.cfg.Context.Done():
+			t.Stop()
+			return fmt.Errorf("%w: %v", w.cfg.Context.Err(), err)
+		}
+		backoff *= 2
+	}
+}
+
+// body returns the request body, compressed if needed
+func (w *webhookWriter) body(stats []stat) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	var out io.Writer = buf
+
+	var gz *gzip.Writer
+	if w.cfg.Gzip {
+		gz = gzip.NewWriter(buf)
+		out = gz
+	}
+
+	if _, err := io.WriteString(out, `{"stats":[`); err != nil {
+		return nil, err
+	}
+	for i, s := range stats {
+		line, err := marshalJSONLine(s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s: %w", statTypeName(s), err)
+		}
+		if i > 0 {
+			if _, err := io.WriteString(out, ","); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := out.Write(bytes.TrimSuffix(line, []byte("\n"))); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := io.WriteString(out, "]}"); err != nil {
+		return nil, err
+	}
+
+	if gz != nil {
+		if err := gz.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// send returns true if the error is retryable
+func (w *webhookWriter) send(body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(w.cfg.Context, http.MethodPost, w.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.cfg.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if w.cfg.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, webhookSignature(w.cfg.Secret, body))
+	}
+	for k, v := range w.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := w.cfg.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	if resp.StatusCode/100 != 2 {
+		retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode/100 == 5
+		return retryable, fmt.Errorf("unexpected status: %s: %s", resp.Status, respBody)
+	}
+
+	return false, nil
+}
+
+// webhookSignature returns "sha256=<hex>" of HMAC-SHA256 of the body as sent, compressed if gzip is enabled
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,237 @@
+package stats
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// webhookTestServer records the requests and responds with the statuses in order, then 204
+type webhookTestServer struct {
+	*httptest.Server
+	statuses []int
+
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newWebhookTestServer(statuses ...int) *webhookTestServer {
+	s := &webhookTestServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		s.mu.Lock()
+		s.requests = append(s.requests, r)
+		s.bodies = append(s.bodies, body)
+		status := http.StatusNoContent
+		if len(s.statuses) > 0 {
+			status, s.statuses = s.statuses[0], s.statuses[1:]
+		}
+		s.mu.Unlock()
+
+		rw.WriteHeader(status)
+	}))
+
+	return s
+}
+
+func (s *webhookTestServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.requests)
+}
+
+func newWebhookTestWriter(t *testing.T, cfg WebhookWriterConfig) Writer {
+	t.Helper()
+
+	if cfg.Logger == nil {
+		cfg.Logger = zap.NewNop()
+	}
+	w, err := NewWebhookWriter(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return w
+}
+
+func TestWebhookWriterSignature(t *testing.T) {
+	for _, gz := range []bool{false, true} {
+		server := newWebhookTestServer()
+		defer server.Close()
+
+		w := newWebhookTestWriter(t, WebhookWriterConfig{
+			URL:     server.URL,
+			Headers: map[string]string{"Authorization": "Bearer xxx"},
+			Secret:  "secret",
+			Gzip:    gz,
+			Client:  server.Client(),
+		})
+		if err := w.Write(spoolTestStats(1, 2)); err != nil {
+			t.Fatal(err)
+		}
+		if server.count() != 1 {
+			t.Fatalf("unexpected requests: %d", server.count())
+		}
+		req, body := server.requests[0], server.bodies[0]
+
+		// the signature is of the body as sent, compressed if gzip is enabled
+		mac := hmac.New(sha256.New, []byte("secret"))
+		_, _ = mac.Write(body)
+		if got, want := req.Header.Get(WebhookSignatureHeader), "sha256="+hex.EncodeToString(mac.Sum(nil)); got != want {
+			t.Errorf("gzip=%t: unexpected signature: %s, want %s", gz, got, want)
+		}
+		if got := req.Header.Get("Authorization"); got != "Bearer xxx" {
+			t.Errorf("gzip=%t: unexpected header: %q", gz, got)
+		}
+
+		if gz {
+			if got := req.Header.Get("Content-Encoding"); got != "gzip" {
+				t.Errorf("unexpected Content-Encoding: %q", got)
+			}
+			r, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if body, err = ioutil.ReadAll(r); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var payload struct {
+			Stats []map[string]interface{} `json:"stats"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Fatalf("gzip=%t: %s: %s", gz, err, body)
+		}
+		if len(payload.Stats) != 2 || payload.Stats[1]["text_fingerprint"] != float64(2) {
+			t.Errorf("gzip=%t: unexpected payload: %s", gz, body)
+		}
+	}
+}
+
+func TestWebhookWriterMaxBatchSize(t *testing.T) {
+	server := newWebhookTestServer()
+	defer server.Close()
+
+	w := newWebhookTestWriter(t, WebhookWriterConfig{URL: server.URL, MaxBatchSize: 2, Client: server.Client()})
+	if err := w.Write(spoolTestStats(1, 2, 3, 4, 5)); err != nil {
+		t.Fatal(err)
+	}
+
+	var sizes []int
+	for _, body := range server.bodies {
+		var payload struct {
+			Stats []json.RawMessage `json:"stats"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Fatal(err)
+		}
+		sizes = append(sizes, len(payload.Stats))
+	}
+	if len(sizes) != 3 || sizes[0] != 2 || sizes[1] != 2 || sizes[2] != 1 {
+		t.Errorf("unexpected batches: %v", sizes)
+	}
+}
+
+func TestWebhookWriterRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		requests int
+		fail     bool
+	}{
+		{
+			name:     "retryable",
+			statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests},
+			requests: 3,
+		},
+		{
+			name:     "exhausted",
+			statuses: []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable},
+			requests: 3,
+			fail:     true,
+		},
+		{
+			name:     "not retryable",
+			statuses: []int{http.StatusBadRequest},
+			requests: 1,
+			fail:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newWebhookTestServer(tt.statuses...)
+			defer server.Close()
+
+			w := newWebhookTestWriter(t, WebhookWriterConfig{
+				URL:           server.URL,
+				RetryAttempts: 3,
+				RetryBackoff:  time.Millisecond,
+				Client:        server.Client(),
+			})
+			err := w.Write(spoolTestStats(1))
+			if (err != nil) != tt.fail {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if server.count() != tt.requests {
+				t.Errorf("unexpected requests: %d", server.count())
+			}
+		})
+	}
+}
+
+func TestWebhookWriterPartialWrite(t *testing.T) {
+	server := newWebhookTestServer(http.StatusNoContent, http.StatusBadRequest)
+	defer server.Close()
+
+	w := newWebhookTestWriter(t, WebhookWriterConfig{URL: server.URL, MaxBatchSize: 2, Client: server.Client()})
+
+	var partial *PartialWriteError
+	if err := w.Write(spoolTestStats(1, 2, 3)); !errors.As(err, &partial) || partial.Written != 2 {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestWebhookWriterCancel(t *testing.T) {
+	server := newWebhookTestServer(http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := newWebhookTestWriter(t, WebhookWriterConfig{
+		URL:           server.URL,
+		RetryAttempts: 3,
+		RetryBackoff:  time.Hour,
+		Client:        server.Client(),
+		Context:       ctx,
+	})
+
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	start := time.Now()
+	if err := w.Write(spoolTestStats(1)); !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("the retry waits for the backoff after canceled: %s", elapsed)
+	}
+	if server.count() != 1 {
+		t.Errorf("unexpected requests: %d", server.count())
+	}
+}
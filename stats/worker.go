@@ -2,100 +2,328 @@ package stats
 
 import (
 	"context"
+	"os"
+	"sync/atomic"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"golang.org/x/sync/errgroup"
 )
 
-// Worker of stats collector
-type Worker struct {
-	client          *Client
+const (
+	defaultRetryAttempts   = 3
+	defaultRetryBackoff    = time.Second
+	otelMeterNameCollector = "spanner.stats.collector"
+)
+
+// statFamily is the collecting unit of the Worker. Each family keeps its own lastIntervalEnd.
+type statFamily struct {
+	name            string
 	statType        StatDuration
-	writer          Writer
-	ctx             context.Context
-	canceler        context.CancelFunc
+	getter          statGetter
 	lastIntervalEnd time.Time
+	errorCount      int64
 }
 
-// NewWorker returns the new stats collector
-func NewWorker(client *Client, statType StatDuration, writer Writer) *Worker {
-	return &Worker{
-		client:          client,
+// CollectorErrorStat is the number of failed collections of the stat family since starting.
+// It is written on every tick after the first failure of the family, so every writer can alert on broken collection.
+type CollectorErrorStat struct {
+	CollectedAt time.Time
+	Family      string
+	ErrorCount  int64
+}
+
+// not a stat of Spanner, so returns collected time instead.
+func (q *CollectorErrorStat) getIntervalEnd() time.Time {
+	return q.CollectedAt
+}
+
+// checkpointKey is unique per family and StatDuration, because they are the different tables.
+func (f *statFamily) checkpointKey() string {
+	return f.name + "/" + f.statType.String()
+}
+
+func newStatFamily(name string, statType StatDuration, getter statGetter) *statFamily {
+	return &statFamily{
+		name:            name,
 		statType:        statType,
-		writer:          writer,
+		getter:          getter,
 		lastIntervalEnd: time.Now().Add(-2 * statType.Duration()),
 	}
 }
 
+// newStatFamilies returns the families collected at the StatDuration
+func newStatFamilies(client *Client, statType StatDuration) []*statFamily {
+	return []*statFamily{
+		newStatFamily("query", statType, client.getQueryStats),
+		newStatFamily("transaction", statType, client.getTransactionStats),
+		newStatFamily("lock", statType, client.getLockStats),
+		newStatFamily("read", statType, client.getReadStats),
+		newStatFamily("query_total", statType, client.getQueryTotalStats),
+		newStatFamily("transaction_total", statType, client.getTransactionTotalStats),
+		newStatFamily("lock_total", statType, client.getLockTotalStats),
+	}
+}
+
+// newHourlyStatFamilies returns the families which exist only at the hourly granularity
+func newHourlyStatFamilies(client *Client) []*statFamily {
+	return []*statFamily{
+		newStatFamily("table_size", StatDurationHour, client.getTableSizeStats),
+	}
+}
+
+// Worker of stats collector
+type Worker struct {
+	client   *Client
+	statType StatDuration
+	writer   Writer
+	ctx      context.Context
+	canceler context.CancelFunc
+	families []*statFamily
+	hourly   []*statFamily
+
+	checkpointStore CheckpointStore
+
+	logger        *zap.Logger
+	retryAttempts int
+	retryBackoff  time.Duration
+	errorCounter  metric.Int64Counter
+}
+
+// WorkerOption configures the Worker
+type WorkerOption func(*Worker)
+
+// WithCheckpointStore enables to resume each stat family from the saved lastIntervalEnd
+func WithCheckpointStore(store CheckpointStore) WorkerOption {
+	return func(w *Worker) {
+		w.checkpointStore = store
+	}
+}
+
+// WithLogger sets the logger for collecting errors
+func WithLogger(logger *zap.Logger) WorkerOption {
+	return func(w *Worker) {
+		w.logger = logger
+	}
+}
+
+// WithRetry sets how many times a stat family is tried in a tick, and the first backoff between them.
+// The backoff is doubled on each retry.
+func WithRetry(attempts int, backoff time.Duration) WorkerOption {
+	return func(w *Worker) {
+		if attempts > 0 {
+			w.retryAttempts = attempts
+		}
+		w.retryBackoff = backoff
+	}
+}
+
+// NewWorker returns the new stats collector
+func NewWorker(client *Client, statType StatDuration, writer Writer, opts ...WorkerOption) *Worker {
+	w := &Worker{
+		client:   client,
+		statType: statType,
+		writer:   writer,
+		families: newStatFamilies(client, statType),
+		// these tables exist only at the hourly granularity, so they run on their own schedule.
+		hourly: newHourlyStatFamilies(client),
+
+		logger:        newDefaultLogger(),
+		retryAttempts: defaultRetryAttempts,
+		retryBackoff:  defaultRetryBackoff,
+		errorCounter: metric.Must(global.Meter(otelMeterNameCollector)).
+			NewInt64Counter(otelMeterNameCollector + ".Errors"),
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
+}
+
 // Start the stats collector
 func (w *Worker) Start(ctx context.Context) {
 	w.ctx, w.canceler = context.WithCancel(ctx)
 
+	w.loadCheckpoints(w.ctx)
+
+	eg, ctx := errgroup.WithContext(w.ctx)
+	eg.Go(func() error { w.run(ctx, w.statType.Duration(), w.families); return nil })
+	eg.Go(func() error { w.run(ctx, StatDurationHour.Duration(), w.hourly); return nil })
+
+	_ = eg.Wait()
+}
+
+// Stop the stats collector
+func (w *Worker) Stop() {
+	w.canceler()
+
+	// the buffered stats would be lost on shutdown
+	if f, ok := w.writer.(Flusher); ok {
+		f.Flush()
+	}
+}
+
+// ErrorCounts returns the number of failed collections of each stat family, for alerting broken collection.
+// The same counts are recorded as the OpenTelemetry counter "spanner.stats.collector.Errors" with "family" attribute,
+// and written as CollectorErrorStat to the Writer.
+func (w *Worker) ErrorCounts() map[string]int64 {
+	counts := map[string]int64{}
+	for _, family := range append(append([]*statFamily{}, w.families...), w.hourly...) {
+		counts[family.checkpointKey()] = atomic.LoadInt64(&family.errorCount)
+	}
+
+	return counts
+}
+
+func (w *Worker) loadCheckpoints(ctx context.Context) {
+	if w.checkpointStore == nil {
+		return
+	}
+
+	for _, family := range append(append([]*statFamily{}, w.families...), w.hourly...) {
+		t, ok, err := w.checkpointStore.Load(ctx, family.checkpointKey())
+		if err != nil {
+			w.logger.Error("failed to load checkpoint", zap.String("family", family.checkpointKey()), zap.Error(err))
+			continue
+		}
+		if ok {
+			family.lastIntervalEnd = t
+		}
+	}
+}
+
+func (w *Worker) saveCheckpoint(ctx context.Context, family *statFamily) {
+	if w.checkpointStore == nil {
+		return
+	}
+
+	if err := w.checkpointStore.Save(ctx, family.checkpointKey(), family.lastIntervalEnd); err != nil {
+		w.logger.Error("failed to save checkpoint", zap.String("family", family.checkpointKey()), zap.Error(err))
+	}
+}
+
+func (w *Worker) run(ctx context.Context, d time.Duration, families []*statFamily) {
 	// in the first time, do it as soon as possible.
-	w.ticker(w.ctx)
+	w.ticker(ctx, families)
 
-	timer := time.NewTicker(w.statType.Duration())
+	timer := time.NewTicker(d)
 	defer timer.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-w.ctx.Done():
-			return
 		case <-timer.C:
-			w.ticker(w.ctx)
+			w.ticker(ctx, families)
 		}
 	}
 }
 
-// Stop the stats collector
-func (w *Worker) Stop() {
-	w.canceler()
-}
-
-func (w *Worker) ticker(ctx context.Context) {
+func (w *Worker) ticker(ctx context.Context, families []*statFamily) {
 	eg, ctx := errgroup.WithContext(ctx)
 
-	getters := []statGetter{
-		w.client.getQueryStats,
-		w.client.getTransactionStats,
-		w.client.getLockStats,
-	}
-	for _, getter := range getters {
-		getter := getter
+	for _, family := range families {
+		family := family
 		eg.Go(func() error {
-			stats := w.getStat(ctx, getter)
+			stats := w.getStat(ctx, family)
 			if len(stats) == 0 {
 				return nil
 			}
-			w.writer.Write(stats)
+			if err := w.writer.Write(stats); err != nil {
+				// lastIntervalEnd and the checkpoint are kept, to collect them again on the next tick or after restarting
+				w.logger.Error("failed to write stats", zap.String("family", family.checkpointKey()), zap.Error(err))
+				return nil
+			}
+
+			// every interval after lastIntervalEnd is emitted. stats are sorted in ascending order,
+			// so the last one is the newest.
+			family.lastIntervalEnd = stats[len(stats)-1].getIntervalEnd()
+			w.saveCheckpoint(ctx, family)
 
 			return nil
 		})
 	}
 
 	_ = eg.Wait()
+
+	w.writeErrorStats(families)
+}
+
+// writeErrorStats writes the error counts of the families which have ever failed
+func (w *Worker) writeErrorStats(families []*statFamily) {
+	now := time.Now()
+
+	var stats []stat
+	for _, family := range families {
+		if n := atomic.LoadInt64(&family.errorCount); n > 0 {
+			stats = append(stats, &CollectorErrorStat{
+				CollectedAt: now,
+				Family:      family.checkpointKey(),
+				ErrorCount:  n,
+			})
+		}
+	}
+	if len(stats) == 0 {
+		return
+	}
+
+	if err := w.writer.Write(stats); err != nil {
+		w.logger.Error("failed to write error counts", zap.Error(err))
+	}
 }
 
 func (w *Worker) getStat(
 	ctx context.Context,
-	getter statGetter,
+	family *statFamily,
 ) []stat {
-	stats := getter(ctx, w.statType, w.lastIntervalEnd)
-	if len(stats) == 0 {
+	stats, err := w.getStatWithRetry(ctx, family)
+	if err != nil {
+		atomic.AddInt64(&family.errorCount, 1)
+		w.errorCounter.Add(ctx, 1, attribute.String("family", family.checkpointKey()))
+		w.logger.Error("failed to collect stats", zap.String("family", family.checkpointKey()), zap.Error(err))
 		return nil
 	}
 
-	// filter last 1 intervalEnd
-	e := stats[0].getIntervalEnd()
-	for i, s := range stats {
-		if e != s.getIntervalEnd() || w.lastIntervalEnd.After(s.getIntervalEnd()) {
-			stats = stats[:i]
-			w.lastIntervalEnd = e
-			break
+	return stats
+}
+
+func (w *Worker) getStatWithRetry(ctx context.Context, family *statFamily) ([]stat, error) {
+	backoff := w.retryBackoff
+
+	for attempt := 1; ; attempt++ {
+		stats, err := family.getter(ctx, family.statType, family.lastIntervalEnd, time.Now())
+		if err == nil || attempt >= w.retryAttempts {
+			return stats, err
+		}
+
+		w.logger.Warn(
+			"retry collecting stats",
+			zap.String("family", family.checkpointKey()),
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", backoff),
+			zap.Error(err),
+		)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
 		}
+		backoff *= 2
 	}
+}
 
-	return stats
+// newDefaultLogger returns the logger writes to stderr
+func newDefaultLogger() *zap.Logger {
+	return zap.New(zapcore.NewCore(
+		zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()),
+		zapcore.Lock(os.Stderr),
+		zap.InfoLevel,
+	))
 }
@@ -0,0 +1,153 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type failingWriter struct {
+	fail    bool
+	written []stat
+}
+
+func (w *failingWriter) Write(stats []stat) error {
+	if w.fail {
+		return errors.New("unavailable")
+	}
+	w.written = append(w.written, stats...)
+
+	return nil
+}
+
+type memoryCheckpointStore map[string]time.Time
+
+func (s memoryCheckpointStore) Load(_ context.Context, key string) (time.Time, bool, error) {
+	t, ok := s[key]
+	return t, ok, nil
+}
+
+func (s memoryCheckpointStore) Save(_ context.Context, key string, t time.Time) error {
+	s[key] = t
+	return nil
+}
+
+func TestWorkerKeepsPositionOnWriteFailure(t *testing.T) {
+	start := time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC)
+	intervals := []time.Time{start.Add(time.Minute), start.Add(2 * time.Minute)}
+
+	var froms []time.Time
+	family := newStatFamily("query", StatDurationMin, func(_ context.Context, _ StatDuration, from, to time.Time) ([]stat, error) {
+		froms = append(froms, from)
+
+		var stats []stat
+		for _, t := range intervals {
+			if t.After(from) {
+				stats = append(stats, &QueryStat{IntervalEnd: t})
+			}
+		}
+		return stats, nil
+	})
+	family.lastIntervalEnd = start
+
+	writer := &failingWriter{fail: true}
+	store := memoryCheckpointStore{}
+	w := NewWorker(nil, StatDurationMin, writer, WithLogger(zap.NewNop()), WithCheckpointStore(store))
+
+	ctx := context.Background()
+	w.ticker(ctx, []*statFamily{family})
+
+	if !family.lastIntervalEnd.Equal(start) {
+		t.Errorf("position moved forward on failure: %s", family.lastIntervalEnd)
+	}
+	if _, ok := store[family.checkpointKey()]; ok {
+		t.Error("checkpoint is saved on failure")
+	}
+
+	writer.fail = false
+	w.ticker(ctx, []*statFamily{family})
+
+	if !froms[1].Equal(start) {
+		t.Errorf("the failed intervals are not collected again: from %s", froms[1])
+	}
+	if len(writer.written) != 2 {
+		t.Errorf("unexpected written: %d", len(writer.written))
+	}
+	if !family.lastIntervalEnd.Equal(intervals[1]) || !store[family.checkpointKey()].Equal(intervals[1]) {
+		t.Errorf("position is not moved forward: %s, checkpoint %s", family.lastIntervalEnd, store[family.checkpointKey()])
+	}
+}
+
+func TestWorkerCatchesUpAfterGap(t *testing.T) {
+	start := time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC)
+
+	// the fake table has two rows of each interval in ascending order, like ORDER BY interval_end
+	var intervals []time.Time
+	var froms []time.Time
+	family := newStatFamily("query", StatDurationMin, func(_ context.Context, _ StatDuration, from, to time.Time) ([]stat, error) {
+		froms = append(froms, from)
+
+		var stats []stat
+		for i, t := range intervals {
+			if t.After(from) {
+				stats = append(stats,
+					&QueryStat{IntervalEnd: t, TextFingerprint: int64(i * 2)},
+					&QueryStat{IntervalEnd: t, TextFingerprint: int64(i*2 + 1)},
+				)
+			}
+		}
+		return stats, nil
+	})
+
+	writer := &failingWriter{}
+	store := memoryCheckpointStore{family.checkpointKey(): start}
+	w := NewWorker(nil, StatDurationMin, writer, WithLogger(zap.NewNop()), WithCheckpointStore(store))
+	w.families = []*statFamily{family}
+	w.hourly = nil
+
+	ctx := context.Background()
+	w.loadCheckpoints(ctx)
+
+	intervals = append(intervals, start.Add(time.Minute))
+	w.ticker(ctx, w.families)
+
+	// the collector was down for several intervals
+	for i := 2; i <= 5; i++ {
+		intervals = append(intervals, start.Add(time.Duration(i)*time.Minute))
+	}
+	writer.written = nil
+	w.ticker(ctx, w.families)
+
+	if len(froms) != 2 || !froms[0].Equal(start) || !froms[1].Equal(intervals[0]) {
+		t.Fatalf("unexpected froms: %v", froms)
+	}
+
+	// every missed interval is written once in ascending order
+	if len(writer.written) != 8 {
+		t.Fatalf("unexpected written: %d", len(writer.written))
+	}
+	for i, s := range writer.written {
+		if want := intervals[1+i/2]; !s.getIntervalEnd().Equal(want) {
+			t.Errorf("unexpected interval_end of %d: %s, want %s", i, s.getIntervalEnd(), want)
+		}
+		if f := s.(*QueryStat).TextFingerprint; f != int64(2+i) {
+			t.Errorf("unexpected order of %d: %d", i, f)
+		}
+	}
+
+	// the checkpoint ends at the last one
+	last := intervals[len(intervals)-1]
+	if !family.lastIntervalEnd.Equal(last) || !store[family.checkpointKey()].Equal(last) {
+		t.Errorf("unexpected position: %s, checkpoint %s", family.lastIntervalEnd, store[family.checkpointKey()])
+	}
+
+	// nothing is written again on the next tick
+	writer.written = nil
+	w.ticker(ctx, w.families)
+	if len(writer.written) != 0 {
+		t.Errorf("written again: %d", len(writer.written))
+	}
+}
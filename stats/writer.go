@@ -2,6 +2,8 @@ package stats
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"strings"
 
 	"go.opentelemetry.io/otel/attribute"
@@ -12,18 +14,49 @@ import (
 
 // Writer for stats collection
 type Writer interface {
-	// Write stats collection to anything
-	Write([]stat)
+	// Write stats collection to anything. It returns error when the stats could not be written,
+	// like the sink is unavailable, so the caller can retry or spool them.
+	Write([]stat) error
+}
+
+// PartialWriteError is returned by the Writers which write stats in chunks, like webhook and influxdb,
+// when the first Written stats were written before the failure. SpoolWriter keeps only the rest.
+type PartialWriteError struct {
+	Written int
+	Err     error
+}
+
+func (e *PartialWriteError) Error() string {
+	return fmt.Sprintf("%s (written %d stats)", e.Err, e.Written)
+}
+
+func (e *PartialWriteError) Unwrap() error {
+	return e.Err
+}
+
+// partiallyWritten returns the number of stats written before the error
+func partiallyWritten(err error, stats []stat) int {
+	var partial *PartialWriteError
+	if !errors.As(err, &partial) || partial.Written < 0 {
+		return 0
+	}
+	if partial.Written > len(stats) {
+		return len(stats)
+	}
+
+	return partial.Written
 }
 
 type zapWriter struct {
 	logger *zap.Logger
 }
 
-func (w *zapWriter) Write(stats []stat) {
+func (w *zapWriter) Write(stats []stat) error {
 	for _, s := range stats {
 		w.logger.Info("spanner stats", w.getFields(s)...)
 	}
+
+	return nil
 }
 
 func (w *zapWriter) getFields(s stat) []zap.Field {
@@ -68,6 +101,99 @@ func (w *zapWriter) getFields(s stat) []zap.Field {
 			zap.Float64("LockWaitSeconds", s.LockWaitSeconds),
 			zap.Any("SampleLockRequests", s.SampleLockRequests),
 		}
+
+	case *ReadStat:
+		return []zap.Field{
+			zap.String("type", "ReadStat"),
+			zap.Time("IntervalEnd", s.IntervalEnd),
+			zap.Strings("ReadColumns", s.ReadColumns),
+			zap.Int64("Fprint", s.Fprint),
+			zap.Int64("ExecutionCount", s.ExecutionCount),
+			zap.Float64("AvgRows", s.AvgRows),
+			zap.Float64("AvgBytes", s.AvgBytes),
+			zap.Float64("AvgCPUSeconds", s.AvgCPUSeconds),
+			zap.Float64("AvgLockingDelaySeconds", s.AvgLockingDelaySeconds),
+			zap.Float64("AvgClientWaitSeconds", s.AvgClientWaitSeconds),
+			zap.Float64("AvgLeaderRefreshDelaySeconds", s.AvgLeaderRefreshDelaySeconds),
+		}
+
+	case *QueryTotalStat:
+		return []zap.Field{
+			zap.String("type", "QueryTotalStat"),
+			zap.Time("IntervalEnd", s.IntervalEnd),
+			zap.Int64("ExecutionCount", s.ExecutionCount),
+			zap.Float64("AvgLatencySeconds", s.AvgLatencySeconds),
+			zap.Float64("AvgRows", s.AvgRows),
+			zap.Float64("AvgBytes", s.AvgBytes),
+			zap.Float64("AvgRowsScanned", s.AvgRowsScanned),
+			zap.Float64("AvgCPUSeconds", s.AvgCPUSeconds),
+		}
+
+	case *TransactionTotalStat:
+		return []zap.Field{
+			zap.String("type", "TransactionTotalStat"),
+			zap.Time("IntervalEnd", s.IntervalEnd),
+			zap.Int64("CommitAttemptCount", s.CommitAttemptCount),
+			zap.Int64("CommitFailedPreconditionCount", s.CommitFailedPreconditionCount),
+			zap.Int64("CommitAbortCount", s.CommitAbortCount),
+			zap.Float64("AvgParticipants", s.AvgParticipants),
+			zap.Float64("AvgTotalLatencySeconds", s.AvgTotalLatencySeconds),
+			zap.Float64("AvgCommitLatencySeconds", s.AvgCommitLatencySeconds),
+			zap.Float64("AvgBytes", s.AvgBytes),
+		}
+
+	case *LockTotalStat:
+		return []zap.Field{
+			zap.String("type", "LockTotalStat"),
+			zap.Time("IntervalEnd", s.IntervalEnd),
+			zap.Float64("TotalLockWaitSeconds", s.TotalLockWaitSeconds),
+		}
+
+	case *TableSizeStat:
+		return []zap.Field{
+			zap.String("type", "TableSizeStat"),
+			zap.Time("IntervalEnd", s.IntervalEnd),
+			zap.String("TableName", s.TableName),
+			zap.Float64("UsedBytes", s.UsedBytes),
+		}
+
+	case *OldestActiveQueryStat:
+		return []zap.Field{
+			zap.String("type", "OldestActiveQueryStat"),
+			zap.Time("CollectedAt", s.CollectedAt),
+			zap.Time("StartTime", s.StartTime),
+			zap.Int64("TextFingerprint", s.TextFingerprint),
+			zap.String("Text", s.Text),
+			zap.Bool("TextTruncated", s.TextTruncated),
+			zap.String("SessionID", s.SessionID),
+			zap.Duration("Elapsed", s.Elapsed),
+		}
+
+	case *ActivePartitionedDMLStat:
+		return []zap.Field{
+			zap.String("type", "ActivePartitionedDMLStat"),
+			zap.Time("CollectedAt", s.CollectedAt),
+			zap.String("Text", s.Text),
+			zap.Int64("TextFingerprint", s.TextFingerprint),
+			zap.String("SessionID", s.SessionID),
+			zap.Int64("NumPartitionsTotal", s.NumPartitionsTotal),
+			zap.Int64("NumPartitionsComplete", s.NumPartitionsComplete),
+			zap.Int64("NumTrivialPartitionsComplete", s.NumTrivialPartitionsComplete),
+			zap.Float64("Progress", s.Progress),
+			zap.Int64("RowsProcessed", s.RowsProcessed),
+			zap.Time("StartTimestamp", s.StartTimestamp),
+			zap.Time("LastUpdateTimestamp", s.LastUpdateTimestamp),
+			zap.Duration("Elapsed", s.Elapsed),
+			zap.Bool("Completed", s.Completed),
+		}
+
+	case *CollectorErrorStat:
+		return []zap.Field{
+			zap.String("type", "CollectorErrorStat"),
+			zap.Time("CollectedAt", s.CollectedAt),
+			zap.String("Family", s.Family),
+			zap.Int64("ErrorCount", s.ErrorCount),
+		}
 	}
 
 	return nil
@@ -84,6 +210,13 @@ type otelWriter struct {
 	query       otelWriterQuery
 	transaction otelWriterTransaction
 	lock        otelWriterLock
+	read        otelWriterRead
+	queryTotal  otelWriterQueryTotal
+	txnTotal    otelWriterTransactionTotal
+	lockTotal   otelWriterLockTotal
+	tableSize   otelWriterTableSize
+	activeQuery otelWriterActiveQuery
+	activeDML   otelWriterActiveDML
 }
 
 type otelWriterQuery struct {
@@ -127,13 +260,91 @@ type otelWriterLockMeasures struct {
 	lockWaitSeconds metric.Float64ValueRecorder
 }
 
+type otelWriterRead struct {
+	meter    metric.Meter
+	measures otelWriterReadMeasures
+}
+
+type otelWriterReadMeasures struct {
+	intervalEnd                  metric.Int64ValueRecorder
+	executionCount               metric.Int64Counter
+	avgRows                      metric.Float64ValueRecorder
+	avgBytes                     metric.Float64ValueRecorder
+	avgCPUSeconds                metric.Float64ValueRecorder
+	avgLockingDelaySeconds       metric.Float64ValueRecorder
+	avgClientWaitSeconds         metric.Float64ValueRecorder
+	avgLeaderRefreshDelaySeconds metric.Float64ValueRecorder
+}
+
+type otelWriterQueryTotal struct {
+	meter    metric.Meter
+	measures otelWriterQueryMeasures
+}
+
+type otelWriterTransactionTotal struct {
+	meter    metric.Meter
+	measures otelWriterTransactionMeasures
+}
+
+type otelWriterLockTotal struct {
+	meter    metric.Meter
+	measures otelWriterLockTotalMeasures
+}
+
+type otelWriterLockTotalMeasures struct {
+	intervalEnd          metric.Int64ValueRecorder
+	totalLockWaitSeconds metric.Float64ValueRecorder
+}
+
+type otelWriterTableSize struct {
+	meter    metric.Meter
+	measures otelWriterTableSizeMeasures
+}
+
+type otelWriterTableSizeMeasures struct {
+	intervalEnd metric.Int64ValueRecorder
+	usedBytes   metric.Float64ValueRecorder
+}
+
+type otelWriterActiveQuery struct {
+	meter    metric.Meter
+	measures otelWriterActiveQueryMeasures
+}
+
+type otelWriterActiveQueryMeasures struct {
+	elapsedSeconds metric.Float64ValueRecorder
+}
+
+type otelWriterActiveDML struct {
+	meter    metric.Meter
+	measures otelWriterActiveDMLMeasures
+}
+
+type otelWriterActiveDMLMeasures struct {
+	numPartitionsTotal    metric.Int64ValueRecorder
+	numPartitionsComplete metric.Int64ValueRecorder
+	progress              metric.Float64ValueRecorder
+	rowsProcessed         metric.Int64ValueRecorder
+	elapsedSeconds        metric.Float64ValueRecorder
+	completed             metric.Int64Counter
+}
+
 const (
 	otelMeterNameQuery       = "spanner.stats.query"
 	otelMeterNameTransaction = "spanner.stats.transaction"
 	otelMeterNameLock        = "spanner.stats.lock"
+	otelMeterNameRead        = "spanner.stats.read"
+
+	otelMeterNameQueryTotal       = "spanner.stats.query_total"
+	otelMeterNameTransactionTotal = "spanner.stats.transaction_total"
+	otelMeterNameLockTotal        = "spanner.stats.lock_total"
+
+	otelMeterNameTableSize   = "spanner.stats.table_size"
+	otelMeterNameActiveQuery = "spanner.stats.oldest_active_query"
+	otelMeterNameActiveDML   = "spanner.stats.active_partitioned_dml"
 )
 
-func (w *otelWriter) Write(stats []stat) {
+func (w *otelWriter) Write(stats []stat) error {
 	for _, s := range stats {
 		switch s := s.(type) {
 		case *QueryStat:
@@ -194,8 +405,106 @@ func (w *otelWriter) Write(stats []stat) {
 				w.lock.measures.intervalEnd.Measurement(s.IntervalEnd.UnixNano()),
 				w.lock.measures.lockWaitSeconds.Measurement(s.LockWaitSeconds),
 			)
+
+		case *ReadStat:
+			w.read.meter.RecordBatch(
+				context.Background(),
+				[]attribute.KeyValue{
+					attribute.String("ReadColumns", strings.Join(s.ReadColumns, ",")),
+					attribute.Int64("Fprint", s.Fprint),
+				},
+				w.read.measures.intervalEnd.Measurement(s.IntervalEnd.UnixNano()),
+				w.read.measures.executionCount.Measurement(s.ExecutionCount),
+				w.read.measures.avgRows.Measurement(s.AvgRows),
+				w.read.measures.avgBytes.Measurement(s.AvgBytes),
+				w.read.measures.avgCPUSeconds.Measurement(s.AvgCPUSeconds),
+				w.read.measures.avgLockingDelaySeconds.Measurement(s.AvgLockingDelaySeconds),
+				w.read.measures.avgClientWaitSeconds.Measurement(s.AvgClientWaitSeconds),
+				w.read.measures.avgLeaderRefreshDelaySeconds.Measurement(s.AvgLeaderRefreshDelaySeconds),
+			)
+
+		case *QueryTotalStat:
+			w.queryTotal.meter.RecordBatch(
+				context.Background(),
+				nil,
+				w.queryTotal.measures.intervalEnd.Measurement(s.IntervalEnd.UnixNano()),
+				w.queryTotal.measures.executionCount.Measurement(s.ExecutionCount),
+				w.queryTotal.measures.avgLatencySeconds.Measurement(s.AvgLatencySeconds),
+				w.queryTotal.measures.avgRows.Measurement(s.AvgRows),
+				w.queryTotal.measures.avgBytes.Measurement(s.AvgBytes),
+				w.queryTotal.measures.avgRowsScanned.Measurement(s.AvgRowsScanned),
+				w.queryTotal.measures.avgCPUSeconds.Measurement(s.AvgCPUSeconds),
+			)
+
+		case *TransactionTotalStat:
+			w.txnTotal.meter.RecordBatch(
+				context.Background(),
+				nil,
+				w.txnTotal.measures.intervalEnd.Measurement(s.IntervalEnd.UnixNano()),
+				w.txnTotal.measures.commitAttemptCount.Measurement(s.CommitAttemptCount),
+				w.txnTotal.measures.commitFailedPreconditionCount.Measurement(s.CommitFailedPreconditionCount),
+				w.txnTotal.measures.commitAbortCount.Measurement(s.CommitAbortCount),
+				w.txnTotal.measures.avgParticipants.Measurement(s.AvgParticipants),
+				w.txnTotal.measures.avgTotalLatencySeconds.Measurement(s.AvgTotalLatencySeconds),
+				w.txnTotal.measures.avgCommitLatencySeconds.Measurement(s.AvgCommitLatencySeconds),
+				w.txnTotal.measures.avgBytes.Measurement(s.AvgBytes),
+			)
+
+		case *LockTotalStat:
+			w.lockTotal.meter.RecordBatch(
+				context.Background(),
+				nil,
+				w.lockTotal.measures.intervalEnd.Measurement(s.IntervalEnd.UnixNano()),
+				w.lockTotal.measures.totalLockWaitSeconds.Measurement(s.TotalLockWaitSeconds),
+			)
+
+		case *TableSizeStat:
+			w.tableSize.meter.RecordBatch(
+				context.Background(),
+				[]attribute.KeyValue{
+					attribute.String("TableName", s.TableName),
+				},
+				w.tableSize.measures.intervalEnd.Measurement(s.IntervalEnd.UnixNano()),
+				w.tableSize.measures.usedBytes.Measurement(s.UsedBytes),
+			)
+
+		case *OldestActiveQueryStat:
+			w.activeQuery.meter.RecordBatch(
+				context.Background(),
+				[]attribute.KeyValue{
+					attribute.String(
+						"Text",
+						strings.NewReplacer("\r", " ", "\n", " ", "\t", " ").Replace(s.Text),
+					),
+					attribute.Int64("TextFingerprint", s.TextFingerprint),
+				},
+				w.activeQuery.measures.elapsedSeconds.Measurement(s.Elapsed.Seconds()),
+			)
+
+		case *ActivePartitionedDMLStat:
+			attrs := []attribute.KeyValue{
+				attribute.String(
+					"Text",
+					strings.NewReplacer("\r", " ", "\n", " ", "\t", " ").Replace(s.Text),
+				),
+				attribute.Int64("TextFingerprint", s.TextFingerprint),
+			}
+			if s.Completed {
+				w.activeDML.measures.completed.Add(context.Background(), 1, attrs...)
+			}
+			w.activeDML.meter.RecordBatch(
+				context.Background(),
+				attrs,
+				w.activeDML.measures.numPartitionsTotal.Measurement(s.NumPartitionsTotal),
+				w.activeDML.measures.numPartitionsComplete.Measurement(s.NumPartitionsComplete),
+				w.activeDML.measures.progress.Measurement(s.Progress),
+				w.activeDML.measures.rowsProcessed.Measurement(s.RowsProcessed),
+				w.activeDML.measures.elapsedSeconds.Measurement(s.Elapsed.Seconds()),
+			)
 		}
 	}
+
+	return nil
 }
 
 // NewOpenTelemetryWriter return new Writer of OpenTelemetry
@@ -206,6 +515,20 @@ func NewOpenTelemetryWriter() Writer {
 	transactionMust := metric.Must(transactionMeter)
 	lockMeter := global.Meter(otelMeterNameLock)
 	lockMust := metric.Must(lockMeter)
+	readMeter := global.Meter(otelMeterNameRead)
+	readMust := metric.Must(readMeter)
+	queryTotalMeter := global.Meter(otelMeterNameQueryTotal)
+	queryTotalMust := metric.Must(queryTotalMeter)
+	txnTotalMeter := global.Meter(otelMeterNameTransactionTotal)
+	txnTotalMust := metric.Must(txnTotalMeter)
+	lockTotalMeter := global.Meter(otelMeterNameLockTotal)
+	lockTotalMust := metric.Must(lockTotalMeter)
+	tableSizeMeter := global.Meter(otelMeterNameTableSize)
+	tableSizeMust := metric.Must(tableSizeMeter)
+	activeQueryMeter := global.Meter(otelMeterNameActiveQuery)
+	activeQueryMust := metric.Must(activeQueryMeter)
+	activeDMLMeter := global.Meter(otelMeterNameActiveDML)
+	activeDMLMust := metric.Must(activeDMLMeter)
 
 	return &otelWriter{
 		query: otelWriterQuery{
@@ -240,5 +563,74 @@ func NewOpenTelemetryWriter() Writer {
 				lockWaitSeconds: lockMust.NewFloat64ValueRecorder(otelMeterNameQuery + ".LockWaitSeconds"),
 			},
 		},
+		read: otelWriterRead{
+			meter: readMeter,
+			measures: otelWriterReadMeasures{
+				intervalEnd:                  readMust.NewInt64ValueRecorder(otelMeterNameRead + ".IntervalEnd"),
+				executionCount:               readMust.NewInt64Counter(otelMeterNameRead + ".ExecutionCount"),
+				avgRows:                      readMust.NewFloat64ValueRecorder(otelMeterNameRead + ".AvgRows"),
+				avgBytes:                     readMust.NewFloat64ValueRecorder(otelMeterNameRead + ".AvgBytes"),
+				avgCPUSeconds:                readMust.NewFloat64ValueRecorder(otelMeterNameRead + ".AvgCpuSeconds"),
+				avgLockingDelaySeconds:       readMust.NewFloat64ValueRecorder(otelMeterNameRead + ".AvgLockingDelaySeconds"),
+				avgClientWaitSeconds:         readMust.NewFloat64ValueRecorder(otelMeterNameRead + ".AvgClientWaitSeconds"),
+				avgLeaderRefreshDelaySeconds: readMust.NewFloat64ValueRecorder(otelMeterNameRead + ".AvgLeaderRefreshDelaySeconds"),
+			},
+		},
+		queryTotal: otelWriterQueryTotal{
+			meter: queryTotalMeter,
+			measures: otelWriterQueryMeasures{
+				intervalEnd:       queryTotalMust.NewInt64ValueRecorder(otelMeterNameQueryTotal + ".IntervalEnd"),
+				executionCount:    queryTotalMust.NewInt64Counter(otelMeterNameQueryTotal + ".ExecutionCount"),
+				avgLatencySeconds: queryTotalMust.NewFloat64ValueRecorder(otelMeterNameQueryTotal + ".AvgLatencySeconds"),
+				avgRows:           queryTotalMust.NewFloat64ValueRecorder(otelMeterNameQueryTotal + ".AvgRows"),
+				avgBytes:          queryTotalMust.NewFloat64ValueRecorder(otelMeterNameQueryTotal + ".AvgBytes"),
+				avgRowsScanned:    queryTotalMust.NewFloat64ValueRecorder(otelMeterNameQueryTotal + ".AvgRowsScanned"),
+				avgCPUSeconds:     queryTotalMust.NewFloat64ValueRecorder(otelMeterNameQueryTotal + ".AvgCpuSeconds"),
+			},
+		},
+		txnTotal: otelWriterTransactionTotal{
+			meter: txnTotalMeter,
+			measures: otelWriterTransactionMeasures{
+				intervalEnd:                   txnTotalMust.NewInt64ValueRecorder(otelMeterNameTransactionTotal + ".IntervalEnd"),
+				commitAttemptCount:            txnTotalMust.NewInt64Counter(otelMeterNameTransactionTotal + ".CommitAttemptCount"),
+				commitFailedPreconditionCount: txnTotalMust.NewInt64Counter(otelMeterNameTransactionTotal + ".CommitFailedPreconditionCount"),
+				commitAbortCount:              txnTotalMust.NewInt64Counter(otelMeterNameTransactionTotal + ".CommitAbortCount"),
+				avgParticipants:               txnTotalMust.NewFloat64ValueRecorder(otelMeterNameTransactionTotal + ".AvgParticipants"),
+				avgTotalLatencySeconds:        txnTotalMust.NewFloat64ValueRecorder(otelMeterNameTransactionTotal + ".AvgTotalLatencySeconds"),
+				avgCommitLatencySeconds:       txnTotalMust.NewFloat64ValueRecorder(otelMeterNameTransactionTotal + ".AvgCommitLatencySeconds"),
+				avgBytes:                      txnTotalMust.NewFloat64ValueRecorder(otelMeterNameTransactionTotal + ".AvgBytes"),
+			},
+		},
+		lockTotal: otelWriterLockTotal{
+			meter: lockTotalMeter,
+			measures: otelWriterLockTotalMeasures{
+				intervalEnd:          lockTotalMust.NewInt64ValueRecorder(otelMeterNameLockTotal + ".IntervalEnd"),
+				totalLockWaitSeconds: lockTotalMust.NewFloat64ValueRecorder(otelMeterNameLockTotal + ".TotalLockWaitSeconds"),
+			},
+		},
+		tableSize: otelWriterTableSize{
+			meter: tableSizeMeter,
+			measures: otelWriterTableSizeMeasures{
+				intervalEnd: tableSizeMust.NewInt64ValueRecorder(otelMeterNameTableSize + ".IntervalEnd"),
+				usedBytes:   tableSizeMust.NewFloat64ValueRecorder(otelMeterNameTableSize + ".UsedBytes"),
+			},
+		},
+		activeQuery: otelWriterActiveQuery{
+			meter: activeQueryMeter,
+			measures: otelWriterActiveQueryMeasures{
+				elapsedSeconds: activeQueryMust.NewFloat64ValueRecorder(otelMeterNameActiveQuery + ".ElapsedSeconds"),
+			},
+		},
+		activeDML: otelWriterActiveDML{
+			meter: activeDMLMeter,
+			measures: otelWriterActiveDMLMeasures{
+				numPartitionsTotal:    activeDMLMust.NewInt64ValueRecorder(otelMeterNameActiveDML + ".NumPartitionsTotal"),
+				numPartitionsComplete: activeDMLMust.NewInt64ValueRecorder(otelMeterNameActiveDML + ".NumPartitionsComplete"),
+				progress:              activeDMLMust.NewFloat64ValueRecorder(otelMeterNameActiveDML + ".Progress"),
+				rowsProcessed:         activeDMLMust.NewInt64ValueRecorder(otelMeterNameActiveDML + ".RowsProcessed"),
+				elapsedSeconds:        activeDMLMust.NewFloat64ValueRecorder(otelMeterNameActiveDML + ".ElapsedSeconds"),
+				completed:             activeDMLMust.NewInt64Counter(otelMeterNameActiveDML + ".Completed"),
+			},
+		},
 	}
 }